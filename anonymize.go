@@ -0,0 +1,95 @@
+package ghreport
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// pseudonymizer maps logins to stable pseudonyms ("user1", "user2"...) in order of first appearance
+type pseudonymizer struct {
+	pseudonyms map[string]string
+}
+
+func (p *pseudonymizer) pseudonym(login string) string {
+	if login == "" {
+		return ""
+	}
+	if pseudonym, ok := p.pseudonyms[login]; ok {
+		return pseudonym
+	}
+	pseudonym := fmt.Sprintf("user%d", len(p.pseudonyms)+1)
+	p.pseudonyms[login] = pseudonym
+	return pseudonym
+}
+
+// anonymizePRs returns a copy of pullrequests with the author, participant and reviewer logins mapped.
+// Team reviewers are kept as is.
+func anonymizePRs(pullrequests []PRStruct, mapLogin func(string) string) []PRStruct {
+	if pullrequests == nil {
+		return nil
+	}
+	anonymized := make([]PRStruct, len(pullrequests))
+	for i, pr := range pullrequests {
+		pr.Author.Login = mapLogin(pr.Author.Login)
+		participants := make([]UserStruct, len(pr.Participants.Nodes))
+		for j, user := range pr.Participants.Nodes {
+			participants[j] = UserStruct{Login: mapLogin(user.Login)}
+		}
+		pr.Participants.Nodes = participants
+		if pr.RequestedReviewers != nil {
+			reviewers := make(ReviewerList, len(pr.RequestedReviewers))
+			for j, reviewer := range pr.RequestedReviewers {
+				if strings.HasPrefix(reviewer, TeamReviewerPrefix) {
+					reviewers[j] = reviewer
+				} else {
+					reviewers[j] = mapLogin(reviewer)
+				}
+			}
+			pr.RequestedReviewers = reviewers
+		}
+		anonymized[i] = pr
+	}
+	return anonymized
+}
+
+// exportResult returns the result to render or export: gr.Result itself, or an anonymized copy
+// when Anonymize or AnonymizeLogin is set. gr.Result is never modified.
+func (gr *ActivityReport) exportResult() Result {
+	if !gr.Anonymize && gr.AnonymizeLogin == nil {
+		return gr.Result
+	}
+	mapLogin := gr.AnonymizeLogin
+	if mapLogin == nil {
+		mapLogin = (&pseudonymizer{pseudonyms: map[string]string{}}).pseudonym
+	}
+
+	result := gr.Result
+	result.MergedPRs = anonymizePRs(result.MergedPRs, mapLogin)
+	result.OpenPRsWithActivity = anonymizePRs(result.OpenPRsWithActivity, mapLogin)
+	result.OpenPRsWithoutActivity = anonymizePRs(result.OpenPRsWithoutActivity, mapLogin)
+	result.ClosedPRs = anonymizePRs(result.ClosedPRs, mapLogin)
+	result.DraftPRs = anonymizePRs(result.DraftPRs, mapLogin)
+	result.StalePRs = anonymizePRs(result.StalePRs, mapLogin)
+	result.ConflictingPRs = anonymizePRs(result.ConflictingPRs, mapLogin)
+	if result.Commits != nil {
+		// Walk the repositories in order so that commit-only authors get the same pseudonyms on each export
+		repoNames := make([]string, 0, len(result.Commits))
+		for repoName := range result.Commits {
+			repoNames = append(repoNames, repoName)
+		}
+		sort.Strings(repoNames)
+		commits := map[string]CommitSummary{}
+		for _, repoName := range repoNames {
+			summary := result.Commits[repoName]
+			authors := make([]string, len(summary.Authors))
+			for i, author := range summary.Authors {
+				authors[i] = mapLogin(author)
+			}
+			summary.Authors = authors
+			commits[repoName] = summary
+		}
+		result.Commits = commits
+	}
+	return result
+}
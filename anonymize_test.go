@@ -0,0 +1,86 @@
+package ghreport
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestAnonymizeJSON(t *testing.T) {
+	report := NewActivityReport("acme", "token", 7)
+	report.Anonymize = true
+	report.Result.MergedPRs = []PRStruct{
+		{Repository: "api", Number: 1, Author: UserStruct{Login: "alice"}},
+		{Repository: "api", Number: 2, Author: UserStruct{Login: "bob"}},
+	}
+	report.Result.MergedPRs[0].Participants.Nodes = []UserStruct{{Login: "alice"}, {Login: "carol"}}
+	report.Result.MergedPRs[1].Participants.Nodes = []UserStruct{{Login: "carol"}}
+	report.Result.MergedPRs[1].RequestedReviewers = ReviewerList{"alice", TeamReviewerPrefix + "core"}
+
+	result := report.exportResult()
+	first, second := result.MergedPRs[0], result.MergedPRs[1]
+	if first.Participants.Nodes[1].Login != second.Participants.Nodes[0].Login {
+		t.Errorf("carol mapped to %q and %q, want the same pseudonym",
+			first.Participants.Nodes[1].Login, second.Participants.Nodes[0].Login)
+	}
+	if first.Author.Login != first.Participants.Nodes[0].Login || first.Author.Login != second.RequestedReviewers[0] {
+		t.Errorf("alice mapped to %q, %q and %q, want the same pseudonym",
+			first.Author.Login, first.Participants.Nodes[0].Login, second.RequestedReviewers[0])
+	}
+	if first.Author.Login == second.Author.Login {
+		t.Errorf("alice and bob both mapped to %q", first.Author.Login)
+	}
+	if second.RequestedReviewers[1] != TeamReviewerPrefix+"core" {
+		t.Errorf("team reviewer mapped to %q", second.RequestedReviewers[1])
+	}
+	if report.Result.MergedPRs[0].Author.Login != "alice" {
+		t.Errorf("the result of the report was modified")
+	}
+
+	var buf bytes.Buffer
+	if err := report.ToJSON(&buf); err != nil {
+		t.Fatal(err)
+	}
+	for _, login := range []string{"alice", "bob", "carol"} {
+		if strings.Contains(buf.String(), login) {
+			t.Errorf("login %s leaked in %s", login, buf.String())
+		}
+	}
+}
+
+func TestAnonymizeLogin(t *testing.T) {
+	report := NewActivityReport("acme", "token", 7)
+	report.AnonymizeLogin = func(login string) string { return strings.ToUpper(login[:1]) + "." }
+	report.Result.MergedPRs = []PRStruct{{Author: UserStruct{Login: "alice"}}}
+	if got := report.exportResult().MergedPRs[0].Author.Login; got != "A." {
+		t.Fatalf("author mapped to %q, want A.", got)
+	}
+}
+
+func TestAnonymizeIsDeterministic(t *testing.T) {
+	report := NewActivityReport("acme", "token", 7)
+	report.Anonymize = true
+	report.Result.MergedPRs = []PRStruct{{Repository: "api", Number: 1, Author: UserStruct{Login: "alice"}}}
+	report.Result.Commits = map[string]CommitSummary{}
+	// Authors of commits only, in many repositories so that map order would show
+	for i := 0; i < 20; i++ {
+		repo := fmt.Sprintf("acme/repo%02d", i)
+		report.Result.Commits[repo] = CommitSummary{Commits: 1, Authors: []string{fmt.Sprintf("dev%02d", i)}}
+	}
+
+	var first, second bytes.Buffer
+	if err := report.ToJSON(&first); err != nil {
+		t.Fatal(err)
+	}
+	if err := report.ToJSON(&second); err != nil {
+		t.Fatal(err)
+	}
+	if first.String() != second.String() {
+		t.Fatalf("anonymizing the same result twice differs:\n%s\n%s", first.String(), second.String())
+	}
+	commits := report.exportResult().Commits
+	if got := commits["acme/repo00"].Authors[0]; got != "user2" {
+		t.Errorf("dev00 mapped to %q, want user2 after the pull request authors", got)
+	}
+}
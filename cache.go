@@ -0,0 +1,70 @@
+package ghreport
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultCacheTTL is the time during which cached repository listings are used by default
+const DefaultCacheTTL = time.Hour
+
+// RepositoryCache stores repository listings between runs.
+// Implementations must be safe for concurrent use and must not return expired entries.
+type RepositoryCache interface {
+	// Get returns the repositories stored for key, and false when there is no valid entry
+	Get(key string) ([]RepositoryStruct, bool)
+	// Set stores the repositories for key during ttl
+	Set(key string, repositories []RepositoryStruct, ttl time.Duration)
+}
+
+type memoryCacheEntry struct {
+	repositories []RepositoryStruct
+	expiresAt    time.Time
+}
+
+// MemoryCache is a RepositoryCache keeping listings in memory
+type MemoryCache struct {
+	mu      sync.Mutex
+	entries map[string]memoryCacheEntry
+}
+
+// NewMemoryCache makes a new empty MemoryCache.
+func NewMemoryCache() *MemoryCache {
+	return &MemoryCache{entries: map[string]memoryCacheEntry{}}
+}
+
+// Get implements RepositoryCache
+func (c *MemoryCache) Get(key string) ([]RepositoryStruct, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[key]
+	if !ok || time.Now().After(entry.expiresAt) {
+		delete(c.entries, key)
+		return nil, false
+	}
+	return append([]RepositoryStruct{}, entry.repositories...), true
+}
+
+// Set implements RepositoryCache
+func (c *MemoryCache) Set(key string, repositories []RepositoryStruct, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[key] = memoryCacheEntry{
+		repositories: append([]RepositoryStruct{}, repositories...),
+		expiresAt:    time.Now().Add(ttl),
+	}
+}
+
+// repositoryCacheKey identifies the listing of an organization in the RepositoryCache
+func (gr *ActivityReport) repositoryCacheKey(organization string) string {
+	mode := "full"
+	if !gr.FullScan {
+		mode = "subset"
+	}
+	account := gr.AccountType
+	if account == "" {
+		account = AccountOrganization
+	}
+	return gr.BaseURL + "|" + account + "|" + organization + "|" + mode + "|" + strings.Join(gr.affiliations(), ",")
+}
@@ -0,0 +1,103 @@
+package ghreport
+
+import (
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeCache is a RepositoryCache recording the TTL of the stored listings
+type fakeCache struct {
+	entries map[string][]RepositoryStruct
+	ttls    []time.Duration
+}
+
+func (c *fakeCache) Get(key string) ([]RepositoryStruct, bool) {
+	repositories, ok := c.entries[key]
+	return repositories, ok
+}
+
+func (c *fakeCache) Set(key string, repositories []RepositoryStruct, ttl time.Duration) {
+	c.entries[key] = repositories
+	c.ttls = append(c.ttls, ttl)
+}
+
+func TestRunUsesCachedListing(t *testing.T) {
+	var listings, reports int32
+	server := newGraphQLServer(t, func(req graphQLRequest, w http.ResponseWriter) {
+		if isListing(req) {
+			atomic.AddInt32(&listings, 1)
+			fmt.Fprint(w, listingJSON("api", "web"))
+			return
+		}
+		atomic.AddInt32(&reports, 1)
+		repo, _ := req.Variables["repo"].(string)
+		fmt.Fprint(w, repositoryJSON(repo, ""))
+	})
+	cache := &fakeCache{entries: map[string][]RepositoryStruct{}}
+	report := newTestReport(server.URL)
+	report.RepositoryCache = cache
+	report.RepositoryCacheTTL = 5 * time.Minute
+	for i := 0; i < 2; i++ {
+		if err := report.Run(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if listings != 1 {
+		t.Fatalf("%d listing queries, want 1", listings)
+	}
+	if reports != 4 {
+		t.Fatalf("%d repository queries, want 2 per run", reports)
+	}
+	if len(cache.ttls) != 1 || cache.ttls[0] != 5*time.Minute {
+		t.Fatalf("Set called with TTLs %v, want [5m]", cache.ttls)
+	}
+}
+
+func TestMemoryCacheExpires(t *testing.T) {
+	cache := NewMemoryCache()
+	cache.Set("acme", []RepositoryStruct{{Name: "api"}}, time.Hour)
+	cache.Set("expired", []RepositoryStruct{{Name: "web"}}, -time.Second)
+	if repositories, ok := cache.Get("acme"); !ok || len(repositories) != 1 || repositories[0].Name != "api" {
+		t.Errorf("Get(acme) = %v, %v", repositories, ok)
+	}
+	if _, ok := cache.Get("expired"); ok {
+		t.Error("Get(expired) returned an expired entry")
+	}
+	if _, ok := cache.Get("unknown"); ok {
+		t.Error("Get(unknown) returned an entry")
+	}
+}
+
+func TestRunCountsSavedListingQueries(t *testing.T) {
+	var listings []graphQLRequest
+	server := newPagedListingServer(t, [][]string{{"api", "web"}, {"docs", "cli"}, {"site"}}, &listings)
+	report := newTestReport(server.URL)
+	report.PageSize = 2
+	report.RepositoryCache = NewMemoryCache()
+
+	if err := report.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if len(listings) != 3 || report.Result.CachedListings != 0 || report.Result.SavedQueries != 0 {
+		t.Fatalf("first run: %d listing queries, %d cached listings and %d saved queries, want 3, 0 and 0",
+			len(listings), report.Result.CachedListings, report.Result.SavedQueries)
+	}
+
+	listings = nil
+	if err := report.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if len(listings) != 0 {
+		t.Errorf("second run: %d listing queries, want none", len(listings))
+	}
+	if report.Result.CachedListings != 1 || report.Result.SavedQueries != 3 {
+		t.Errorf("second run: %d cached listings and %d saved queries, want 1 and 3",
+			report.Result.CachedListings, report.Result.SavedQueries)
+	}
+	if report.Result.TotalRepositories != 5 {
+		t.Errorf("second run: %d repositories, want 5", report.Result.TotalRepositories)
+	}
+}
@@ -0,0 +1,166 @@
+package ghreport
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// CheckpointStore keeps the results of the repositories already reported, so that a run
+// interrupted before the end of the scan can be resumed without querying them again.
+// Implementations must be safe for concurrent use.
+type CheckpointStore interface {
+	// Load returns the results stored for key, by repository name
+	Load(key string) map[string]*Result
+	// Save stores the result of a repository for key
+	Save(key string, repository string, result *Result)
+	// Clear removes the results stored for key, once its scan is complete
+	Clear(key string)
+}
+
+// MemoryCheckpointStore is a CheckpointStore keeping results in memory
+type MemoryCheckpointStore struct {
+	mu      sync.Mutex
+	entries map[string]map[string]*Result
+}
+
+// NewMemoryCheckpointStore makes a new empty MemoryCheckpointStore.
+func NewMemoryCheckpointStore() *MemoryCheckpointStore {
+	return &MemoryCheckpointStore{entries: map[string]map[string]*Result{}}
+}
+
+// Load implements CheckpointStore
+func (s *MemoryCheckpointStore) Load(key string) map[string]*Result {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	results := map[string]*Result{}
+	for repository, result := range s.entries[key] {
+		results[repository] = result
+	}
+	return results
+}
+
+// Save implements CheckpointStore
+func (s *MemoryCheckpointStore) Save(key string, repository string, result *Result) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.entries[key] == nil {
+		s.entries[key] = map[string]*Result{}
+	}
+	s.entries[key][repository] = result
+}
+
+// Clear implements CheckpointStore
+func (s *MemoryCheckpointStore) Clear(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.entries, key)
+}
+
+// checkpointKey identifies the scan of an organization over a window in the CheckpointStore.
+// The bounds of the window relative to the run date (the start without StartDate, the end
+// without EndDate) are identified by the duration and the day of the run, so that a run
+// resumes the scans started the same day and a new window starts fresh.
+// The key also covers the options changing the result of a repository, see filterFingerprint.
+func (gr *ActivityReport) checkpointKey(organization string, since time.Time, until time.Time) string {
+	start := fmt.Sprintf("%dd", gr.Duration)
+	if !gr.StartDate.IsZero() {
+		start = since.UTC().Format(ISO_FORM)
+	}
+	end := until.Format("2006-01-02")
+	if !gr.EndDate.IsZero() {
+		end = until.UTC().Format(ISO_FORM)
+	}
+	return gr.BaseURL + "|" + organization + "|" + start + "|" + end + "|" + gr.filterFingerprint()
+}
+
+// filterFingerprint returns a hash of the options filtering and classifying the pull requests,
+// issues and commits of a repository, so that a run with other options doesn't resume the
+// results of a previous one
+func (gr *ActivityReport) filterFingerprint() string {
+	// Marshaling sorts the keys of StateMapping, so the fingerprint doesn't depend on their order
+	key, _ := json.Marshal(struct {
+		AccountType        string
+		Authors            []string
+		LabelFilter        []string
+		PathFilter         []string
+		StateMapping       map[string]PRBucket
+		ExcludeBots        bool
+		BotPatterns        []string
+		BaseBranch         string
+		SeparateDrafts     bool
+		ActivityThreshold  int
+		CountRecentCommits bool
+		StaleAfter         time.Duration
+		MergedOrder        PROrder
+		DefaultBranchOnly  bool
+		PageSize           int
+		RepositoryQuery    string
+		AllowPartialData   bool
+	}{
+		gr.AccountType,
+		gr.Authors,
+		gr.LabelFilter,
+		gr.PathFilter,
+		gr.StateMapping,
+		gr.ExcludeBots,
+		gr.BotPatterns,
+		gr.BaseBranch,
+		gr.SeparateDrafts,
+		gr.ActivityThreshold,
+		gr.CountRecentCommits,
+		gr.StaleAfter,
+		gr.MergedOrder,
+		gr.DefaultBranchOnly,
+		gr.PageSize,
+		gr.RepositoryQuery,
+		gr.AllowPartialData,
+	})
+	sum := sha256.Sum256(key)
+	return hex.EncodeToString(sum[:8])
+}
+
+// clearCheckpoints removes the results stored in Checkpoints for the organizations of
+// repositories, once their scan is complete, so that the next run reports them again
+func (gr *ActivityReport) clearCheckpoints(repositories []repositoryRef, since time.Time, until time.Time) {
+	if gr.Checkpoints == nil {
+		return
+	}
+	cleared := map[string]bool{}
+	for _, repo := range repositories {
+		if !cleared[repo.Organization] {
+			gr.Checkpoints.Clear(gr.checkpointKey(repo.Organization, since, until))
+			cleared[repo.Organization] = true
+		}
+	}
+}
+
+// resumeCheckpoint returns the results stored in Checkpoints for the repositories already
+// reported, and the repositories remaining to report
+func (gr *ActivityReport) resumeCheckpoint(
+	repositories []repositoryRef,
+	since time.Time,
+	until time.Time) ([]*Result, []repositoryRef) {
+
+	if gr.Checkpoints == nil {
+		return nil, repositories
+	}
+	stored := map[string]map[string]*Result{}
+	resumed := []*Result{}
+	remaining := []repositoryRef{}
+	for _, repo := range repositories {
+		if _, ok := stored[repo.Organization]; !ok {
+			stored[repo.Organization] = gr.Checkpoints.Load(gr.checkpointKey(repo.Organization, since, until))
+		}
+		if result, ok := stored[repo.Organization][repo.Name]; ok && result != nil {
+			gr.logf("Resuming %s/%s from checkpoint\n", repo.Organization, repo.Name)
+			resumed = append(resumed, result)
+		} else {
+			remaining = append(remaining, repo)
+		}
+	}
+	return resumed, remaining
+}
@@ -0,0 +1,90 @@
+package ghreport
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newFlakyRepositoryServer lists api and broken, failing the first query of broken,
+// and returns the number of queries received per repository
+func newFlakyRepositoryServer(t *testing.T) (string, func(repo string) int) {
+	var mu sync.Mutex
+	queries := map[string]int{}
+	ts := newGraphQLServer(t, func(req graphQLRequest, w http.ResponseWriter) {
+		if isListing(req) {
+			fmt.Fprint(w, listingJSON("api", "broken"))
+			return
+		}
+		repo, _ := req.Variables["repo"].(string)
+		mu.Lock()
+		queries[repo]++
+		count := queries[repo]
+		mu.Unlock()
+		if repo == "broken" && count == 1 {
+			fmt.Fprint(w, `{"data":{"repository":null},"errors":[{"message":"Could not resolve to a Repository with the name 'acme/broken'."}]}`)
+			return
+		}
+		fmt.Fprint(w, repositoryJSON(repo, fmt.Sprintf(`"mergedPR":{"nodes":[{"number":1,"mergedAt":%q}]}`, daysAgo(1))))
+	})
+	return ts.URL, func(repo string) int {
+		mu.Lock()
+		defer mu.Unlock()
+		return queries[repo]
+	}
+}
+
+func TestRunResumesFromCheckpoint(t *testing.T) {
+	url, queries := newFlakyRepositoryServer(t)
+	store := NewMemoryCheckpointStore()
+
+	report := newTestReport(url)
+	report.Checkpoints = store
+	if err := report.Run(); err == nil {
+		t.Fatal("expected the first run to fail on broken")
+	}
+
+	report = newTestReport(url)
+	report.Checkpoints = store
+	if err := report.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if got := queries("api"); got != 1 {
+		t.Errorf("api was queried %d times, expected it to be resumed from the checkpoint", got)
+	}
+	if got := len(report.Result.MergedPRs); got != 2 {
+		t.Errorf("expected 2 merged pull requests, got %d", got)
+	}
+}
+
+func TestRunClearsCheckpointWhenComplete(t *testing.T) {
+	ts := newRepositoryServer(t, func(repo string) string { return "" }, "api")
+	store := NewMemoryCheckpointStore()
+	report := newTestReport(ts.URL)
+	report.Checkpoints = store
+	if err := report.Run(); err != nil {
+		t.Fatal(err)
+	}
+	since, until := report.window(time.Now())
+	if stored := store.Load(report.checkpointKey("acme", since, until)); len(stored) != 0 {
+		t.Errorf("expected the checkpoint to be cleared, got %v", stored)
+	}
+}
+
+func TestCheckpointKeyDependsOnFilters(t *testing.T) {
+	report := NewActivityReport("acme", "token", 7)
+	since, until := report.window(time.Now())
+	key := report.checkpointKey("acme", since, until)
+
+	report.Authors = []string{"alice"}
+	if report.checkpointKey("acme", since, until) == key {
+		t.Error("expected Authors to change the checkpoint key")
+	}
+	report.Authors = nil
+	report.StateMapping = map[string]PRBucket{"MERGED": BucketClosed}
+	if report.checkpointKey("acme", since, until) == key {
+		t.Error("expected StateMapping to change the checkpoint key")
+	}
+}
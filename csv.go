@@ -0,0 +1,40 @@
+package ghreport
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+	"time"
+)
+
+// HUMAN_FORM is the layout used to display dates in exported reports
+const HUMAN_FORM = "2006-01-02 15:04"
+
+// humanDate converts a GitHub ISO timestamp into HUMAN_FORM, in the report Location.
+// Empty or unparseable values are returned unchanged.
+func (gr *ActivityReport) humanDate(iso string) string {
+	t, err := time.Parse(ISO_FORM, iso)
+	if err != nil {
+		return iso
+	}
+	return t.In(gr.location()).Format(HUMAN_FORM)
+}
+
+// WriteMergedPRsCSV writes the merged pull requests of the report as CSV, one row per pull request
+func (gr *ActivityReport) WriteMergedPRsCSV(w io.Writer) error {
+	result := gr.exportResult()
+	writer := csv.NewWriter(w)
+	writer.Write([]string{"repository", "number", "title", "createdAt", "mergedAt", "participants"})
+	for _, pr := range result.MergedPRs {
+		writer.Write([]string{
+			pr.Repository,
+			strconv.Itoa(pr.Number),
+			pr.Title,
+			gr.humanDate(pr.CreatedAt),
+			gr.humanDate(pr.MergedAt),
+			strconv.Itoa(pr.Participants.TotalCount),
+		})
+	}
+	writer.Flush()
+	return writer.Error()
+}
@@ -0,0 +1,26 @@
+package ghreport
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestWriteMergedPRsCSV(t *testing.T) {
+	report := NewActivityReport("acme", "token", 7)
+	report.Result.MergedPRs = []PRStruct{
+		{Repository: "api", Number: 12, Title: `Fix "quotes", commas`, CreatedAt: "2020-01-01T10:00:00Z", MergedAt: "2020-01-02T11:30:00Z"},
+		{Repository: "web", Number: 3, Title: "Bump", CreatedAt: "not a date"},
+	}
+	report.Result.MergedPRs[0].Participants.TotalCount = 2
+
+	var buf bytes.Buffer
+	if err := report.WriteMergedPRsCSV(&buf); err != nil {
+		t.Fatal(err)
+	}
+	want := "repository,number,title,createdAt,mergedAt,participants\n" +
+		"api,12,\"Fix \"\"quotes\"\", commas\",2020-01-01 10:00,2020-01-02 11:30,2\n" +
+		"web,3,Bump,not a date,,0\n"
+	if buf.String() != want {
+		t.Fatalf("CSV output:\n%s\nwant:\n%s", buf.String(), want)
+	}
+}
@@ -0,0 +1,35 @@
+package ghreport
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+)
+
+// responseTransport passes the body of each successful response to OnResponse, when set,
+// before handing it over to the GraphQL client
+type responseTransport struct {
+	base   http.RoundTripper
+	report *ActivityReport
+}
+
+func (t *responseTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.report.OnResponse == nil {
+		return t.base.RoundTrip(req)
+	}
+	resp, err := t.base.RoundTrip(req)
+	if err != nil || resp.StatusCode >= 500 {
+		return resp, err
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	t.report.responseMu.Lock()
+	defer t.report.responseMu.Unlock()
+	t.report.OnResponse(body)
+	return resp, nil
+}
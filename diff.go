@@ -0,0 +1,65 @@
+package ghreport
+
+// ReportDiff lists the pull requests that changed between two results, e.g. last week's and this
+// week's reports. Pull requests are matched by organization, repository and number.
+type ReportDiff struct {
+	// NewlyMerged holds the pull requests merged in the new result but not in the old one
+	NewlyMerged []PRStruct `json:"newlyMerged"`
+	// NewlyOpened holds the pull requests open (with or without activity, or draft) in the new
+	// result that were not open in the old one
+	NewlyOpened []PRStruct `json:"newlyOpened"`
+	// NewlyIdle holds the pull requests without activity in the new result that had activity
+	// in the old one
+	NewlyIdle []PRStruct `json:"newlyIdle"`
+}
+
+// prKeys returns the keys of the pull requests of every list
+func prKeys(lists ...[]PRStruct) map[prKey]bool {
+	keys := map[prKey]bool{}
+	for _, pullrequests := range lists {
+		for _, pr := range pullrequests {
+			keys[keyOf(pr)] = true
+		}
+	}
+	return keys
+}
+
+// missingFrom returns the pull requests of the lists whose key is not in keys, each one once
+func missingFrom(keys map[prKey]bool, lists ...[]PRStruct) []PRStruct {
+	missing := []PRStruct{}
+	seen := map[prKey]bool{}
+	for _, pullrequests := range lists {
+		for _, pr := range pullrequests {
+			if keys[keyOf(pr)] || seen[keyOf(pr)] {
+				continue
+			}
+			seen[keyOf(pr)] = true
+			missing = append(missing, pr)
+		}
+	}
+	return missing
+}
+
+// DiffReports returns what changed from the old result to the current one.
+// A nil old result is considered empty: every merged and open pull request of current is then current.
+func DiffReports(old *Result, current *Result) *ReportDiff {
+	if old == nil {
+		old = &Result{}
+	}
+	if current == nil {
+		current = &Result{}
+	}
+	diff := &ReportDiff{
+		NewlyMerged: missingFrom(prKeys(old.MergedPRs), current.MergedPRs),
+		NewlyOpened: missingFrom(prKeys(old.OpenPRsWithActivity, old.OpenPRsWithoutActivity, old.DraftPRs),
+			current.OpenPRsWithActivity, current.OpenPRsWithoutActivity, current.DraftPRs),
+		NewlyIdle: []PRStruct{},
+	}
+	wasActive := prKeys(old.OpenPRsWithActivity)
+	for _, pr := range current.OpenPRsWithoutActivity {
+		if wasActive[keyOf(pr)] {
+			diff.NewlyIdle = append(diff.NewlyIdle, pr)
+		}
+	}
+	return diff
+}
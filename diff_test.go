@@ -0,0 +1,54 @@
+package ghreport
+
+import (
+	"testing"
+)
+
+func TestDiffReports(t *testing.T) {
+	pr := func(repository string, number int) PRStruct {
+		return PRStruct{Org: "acme", Repository: repository, Number: number}
+	}
+	old := &Result{
+		MergedPRs:              []PRStruct{pr("api", 1)},
+		OpenPRsWithActivity:    []PRStruct{pr("api", 2), pr("api", 3), pr("web", 4)},
+		OpenPRsWithoutActivity: []PRStruct{pr("api", 5)},
+	}
+	current := &Result{
+		// #2 of api was merged, #1 of web is another pull request than #1 of api
+		MergedPRs:           []PRStruct{pr("api", 1), pr("api", 2), pr("web", 1)},
+		OpenPRsWithActivity: []PRStruct{pr("api", 6)},
+		// #3 of api and #4 of web became idle, #5 of api stayed idle
+		OpenPRsWithoutActivity: []PRStruct{pr("api", 3), pr("web", 4), pr("api", 5)},
+		DraftPRs:               []PRStruct{pr("web", 7)},
+	}
+	diff := DiffReports(old, current)
+	for name, test := range map[string]struct {
+		got      []PRStruct
+		expected []prKey
+	}{
+		"NewlyMerged": {diff.NewlyMerged, []prKey{{"acme", "api", 2}, {"acme", "web", 1}}},
+		"NewlyOpened": {diff.NewlyOpened, []prKey{{"acme", "api", 6}, {"acme", "web", 7}}},
+		"NewlyIdle":   {diff.NewlyIdle, []prKey{{"acme", "api", 3}, {"acme", "web", 4}}},
+	} {
+		if len(test.got) != len(test.expected) {
+			t.Errorf("%s = %+v, want %v", name, test.got, test.expected)
+			continue
+		}
+		for i, pr := range test.got {
+			if keyOf(pr) != test.expected[i] {
+				t.Errorf("%s[%d] = %v, want %v", name, i, keyOf(pr), test.expected[i])
+			}
+		}
+	}
+}
+
+func TestDiffReportsWithoutOldResult(t *testing.T) {
+	current := &Result{
+		MergedPRs:              []PRStruct{{Repository: "api", Number: 1}},
+		OpenPRsWithoutActivity: []PRStruct{{Repository: "api", Number: 2}},
+	}
+	diff := DiffReports(nil, current)
+	if len(diff.NewlyMerged) != 1 || len(diff.NewlyOpened) != 1 || len(diff.NewlyIdle) != 0 {
+		t.Errorf("DiffReports(nil, current) = %+v, want everything new and nothing idle", diff)
+	}
+}
@@ -0,0 +1,52 @@
+package ghreport
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Format identifies an output format supported by Export
+type Format string
+
+const (
+	// FormatJSON writes the report as an indented JSON document, see ToJSON
+	FormatJSON Format = "json"
+	// FormatJSONL writes the pull requests as JSON Lines, see WriteJSONL
+	FormatJSONL Format = "jsonl"
+	// FormatCSV writes the merged pull requests as CSV, see WriteMergedPRsCSV
+	FormatCSV Format = "csv"
+	// FormatTSV writes the pull requests as tab-separated values, see WriteTSV
+	FormatTSV Format = "tsv"
+	// FormatMarkdown writes the report as Markdown, see RenderMarkdown
+	FormatMarkdown Format = "markdown"
+	// FormatHTML writes the report as a self-contained HTML page, see RenderHTML
+	FormatHTML Format = "html"
+)
+
+// Formats lists every format supported by Export
+var Formats = []Format{FormatJSON, FormatJSONL, FormatCSV, FormatTSV, FormatMarkdown, FormatHTML}
+
+// ErrUnknownFormat is returned by Export when the requested format is not supported
+var ErrUnknownFormat = errors.New("Unknown export format")
+
+// Export writes the report to w in the given format. The format values are lowercase
+// names ("json", "jsonl", "csv", "tsv", "markdown", "html") so they can be taken from a command line flag.
+func (gr *ActivityReport) Export(w io.Writer, format Format) error {
+	switch format {
+	case FormatJSON:
+		return gr.ToJSON(w)
+	case FormatJSONL:
+		return gr.WriteJSONL(w)
+	case FormatCSV:
+		return gr.WriteMergedPRsCSV(w)
+	case FormatTSV:
+		return gr.WriteTSV(w)
+	case FormatMarkdown:
+		return gr.RenderMarkdown(w)
+	case FormatHTML:
+		return gr.RenderHTML(w)
+	default:
+		return fmt.Errorf("%w %q (expected one of %v)", ErrUnknownFormat, format, Formats)
+	}
+}
@@ -0,0 +1,33 @@
+package ghreport
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestExportEveryFormat(t *testing.T) {
+	report := NewActivityReport("acme", "token", 7)
+	report.Result.MergedPRs = []PRStruct{{Repository: "api", Number: 1, Title: "Fix", MergedAt: "2020-01-02T10:00:00Z"}}
+	report.Result.OpenPRsWithActivity = []PRStruct{{Repository: "web", Number: 2, Title: "Redesign"}}
+	for _, format := range Formats {
+		var buf bytes.Buffer
+		if err := report.Export(&buf, format); err != nil {
+			t.Errorf("Export(%s) = %v", format, err)
+		}
+		if buf.Len() == 0 {
+			t.Errorf("Export(%s) wrote nothing", format)
+		}
+	}
+}
+
+func TestExportUnknownFormat(t *testing.T) {
+	report := NewActivityReport("acme", "token", 7)
+	var buf bytes.Buffer
+	if err := report.Export(&buf, Format("pdf")); !errors.Is(err, ErrUnknownFormat) {
+		t.Fatalf("Export(pdf) = %v, want ErrUnknownFormat", err)
+	}
+	if buf.Len() != 0 {
+		t.Fatalf("Export(pdf) wrote %q", buf.String())
+	}
+}
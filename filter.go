@@ -0,0 +1,148 @@
+package ghreport
+
+import (
+	"fmt"
+	"path"
+	"strings"
+	"time"
+)
+
+// matchAny reports whether name matches at least one of the glob patterns
+func matchAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// validatePatterns checks that every pattern is a valid glob
+func validatePatterns(field string, patterns []string) error {
+	for _, pattern := range patterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("%s contains an invalid pattern %q: %v", field, pattern, err)
+		}
+	}
+	return nil
+}
+
+// filterRepositories applies IncludeRepos, ExcludeRepos, Visibility and SkipInactiveRepos to the list of repositories.
+// A repository matching both IncludeRepos and ExcludeRepos is excluded.
+func (gr *ActivityReport) filterRepositories(repositories []RepositoryStruct, since time.Time) []RepositoryStruct {
+	filtered := []RepositoryStruct{}
+	for _, repo := range repositories {
+		if gr.keepRepository(repo, since) {
+			filtered = append(filtered, repo)
+		}
+	}
+	return filtered
+}
+
+// keepRepository reports whether a repository passes IncludeRepos, ExcludeRepos, Visibility and SkipInactiveRepos
+func (gr *ActivityReport) keepRepository(repo RepositoryStruct, since time.Time) bool {
+	if len(gr.IncludeRepos) > 0 && !matchAny(gr.IncludeRepos, repo.Name) {
+		return false
+	}
+	if matchAny(gr.ExcludeRepos, repo.Name) {
+		return false
+	}
+	if gr.Visibility != "" && gr.Visibility != VisibilityAll && repo.Visibility != gr.Visibility {
+		gr.logf("Skipping %s repository %s\n", strings.ToLower(repo.Visibility), repo.Name)
+		return false
+	}
+	if gr.SkipInactiveRepos && !pushedSince(repo, since) {
+		gr.logf("Skipping inactive repository %s\n", repo.Name)
+		return false
+	}
+	return true
+}
+
+// pushedSince reports whether the repository received a push after since.
+// Repositories with an unknown push date are considered active.
+func pushedSince(repo RepositoryStruct, since time.Time) bool {
+	t, err := time.Parse(ISO_FORM, repo.PushedAt)
+	if err != nil {
+		return true
+	}
+	return t.After(since)
+}
+
+// containsLogin reports whether logins contains login (GitHub logins are case insensitive)
+func containsLogin(logins []string, login string) bool {
+	for _, l := range logins {
+		if strings.EqualFold(l, login) {
+			return true
+		}
+	}
+	return false
+}
+
+// keepPullRequest reports whether a pull request passes the filters of the report
+func (gr *ActivityReport) keepPullRequest(pr PRStruct) bool {
+	if len(gr.Authors) > 0 && !containsLogin(gr.Authors, pr.Author.Login) {
+		return false
+	}
+	if len(gr.LabelFilter) > 0 && !hasAnyLabel(pr, gr.LabelFilter) {
+		return false
+	}
+	if len(gr.PathFilter) > 0 && !gr.changesAnyPath(pr) {
+		return false
+	}
+	return true
+}
+
+// changesAnyPath reports whether the pull request changes a file matching PathFilter,
+// directly or through one of its parent directories
+func (gr *ActivityReport) changesAnyPath(pr PRStruct) bool {
+	if len(pr.ChangedFiles) < pr.ChangedFileCount {
+		gr.logf("Only the first %d of the %d files changed by %s#%d are checked against PathFilter\n",
+			len(pr.ChangedFiles), pr.ChangedFileCount, pr.Repository, pr.Number)
+	}
+	for _, file := range pr.ChangedFiles {
+		for dir := file; dir != "." && dir != "/" && dir != ""; dir = path.Dir(dir) {
+			if matchAny(gr.PathFilter, dir) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// hasAnyLabel reports whether the pull request carries one of the labels (case insensitive)
+func hasAnyLabel(pr PRStruct, labels []string) bool {
+	for _, label := range pr.Labels {
+		for _, wanted := range labels {
+			if strings.EqualFold(label, wanted) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isBot reports whether login is a bot account: its login ends in "[bot]" or matches BotPatterns
+func (gr *ActivityReport) isBot(login string) bool {
+	return strings.HasSuffix(login, "[bot]") || matchAny(gr.BotPatterns, login)
+}
+
+// withoutBots removes the bots from the participants of a pull request, and clears its author
+// when it is a bot, if ExcludeBots is set
+func (gr *ActivityReport) withoutBots(pr PRStruct) PRStruct {
+	if !gr.ExcludeBots {
+		return pr
+	}
+	if gr.isBot(pr.Author.Login) {
+		pr.Author = UserStruct{}
+	}
+	participants := make([]UserStruct, 0, len(pr.Participants.Nodes))
+	for _, user := range pr.Participants.Nodes {
+		if gr.isBot(user.Login) {
+			pr.Participants.TotalCount--
+			continue
+		}
+		participants = append(participants, user)
+	}
+	pr.Participants.Nodes = participants
+	return pr
+}
@@ -0,0 +1,238 @@
+package ghreport
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// repositoryNames returns the names of repositories
+func repositoryNames(repositories []RepositoryStruct) []string {
+	names := []string{}
+	for _, repo := range repositories {
+		names = append(names, repo.Name)
+	}
+	return names
+}
+
+func TestFilterRepositoriesIncludeExclude(t *testing.T) {
+	repositories := []RepositoryStruct{{Name: "api-users"}, {Name: "api-legacy"}, {Name: "web"}, {Name: "docs"}}
+	for _, test := range []struct {
+		include, exclude []string
+		want             []string
+	}{
+		{nil, nil, []string{"api-users", "api-legacy", "web", "docs"}},
+		{[]string{"api-*"}, nil, []string{"api-users", "api-legacy"}},
+		{nil, []string{"*-legacy", "docs"}, []string{"api-users", "web"}},
+		// ExcludeRepos takes precedence over IncludeRepos
+		{[]string{"api-*"}, []string{"api-legacy"}, []string{"api-users"}},
+	} {
+		report := NewActivityReport("acme", "token", 7)
+		report.IncludeRepos = test.include
+		report.ExcludeRepos = test.exclude
+		got := repositoryNames(report.filterRepositories(repositories, report.ReportDate))
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("include %v exclude %v: got %v, want %v", test.include, test.exclude, got, test.want)
+		}
+	}
+}
+
+func TestValidateRejectsInvalidRepositoryPatterns(t *testing.T) {
+	report := NewActivityReport("acme", "token", 7)
+	report.ExcludeRepos = []string{"api-["}
+	if err := report.Validate(); err == nil {
+		t.Fatal("Validate() accepted an invalid pattern")
+	}
+}
+
+func TestKeepPullRequestMatchesAuthorsIgnoringCase(t *testing.T) {
+	report := NewActivityReport("acme", "token", 7)
+	report.Authors = []string{"Alice", "bob"}
+	for login, want := range map[string]bool{"alice": true, "ALICE": true, "Bob": true, "carol": false, "": false} {
+		pr := PRStruct{Author: UserStruct{Login: login}}
+		if got := report.keepPullRequest(pr); got != want {
+			t.Errorf("keepPullRequest(author %q) = %v, want %v", login, got, want)
+		}
+	}
+}
+
+func TestRunFiltersPullRequestsByLabel(t *testing.T) {
+	server := newRepositoryServer(t, func(repo string) string {
+		return fmt.Sprintf(`"mergedPR":{"nodes":[
+			{"number":1,"mergedAt":%[1]q,"labels":{"nodes":[{"name":"Bug"},{"name":"ui"}]}},
+			{"number":2,"mergedAt":%[1]q,"labels":{"nodes":[{"name":"docs"}]}},
+			{"number":3,"mergedAt":%[1]q,"labels":{"nodes":[]}},
+			{"number":4,"mergedAt":%[1]q}]}`, daysAgo(1))
+	}, "api")
+	report := newTestReport(server.URL)
+	report.LabelFilter = []string{"bug", "security"}
+	if err := report.Run(); err != nil {
+		t.Fatal(err)
+	}
+	merged := report.Result.MergedPRs
+	if len(merged) != 1 || merged[0].Number != 1 {
+		t.Fatalf("MergedPRs = %+v, want only #1", merged)
+	}
+	if !reflect.DeepEqual([]string(merged[0].Labels), []string{"Bug", "ui"}) {
+		t.Errorf("Labels = %v, want [Bug ui]", merged[0].Labels)
+	}
+}
+
+func TestRunFiltersPullRequestsByPath(t *testing.T) {
+	server := newRepositoryServer(t, func(repo string) string {
+		return fmt.Sprintf(`"mergedPR":{"nodes":[
+			{"number":1,"mergedAt":%[1]q,"changedFiles":{"nodes":[{"path":"README.md"},{"path":"docs/setup.md"}]}},
+			{"number":2,"mergedAt":%[1]q,"changedFiles":{"nodes":[{"path":"services/api/main.go"}]}},
+			{"number":3,"mergedAt":%[1]q,"changedFiles":{"nodes":[{"path":"services/web/main.go"},{"path":"docs/img/logo.png"}]}},
+			{"number":4,"mergedAt":%[1]q}]}`, daysAgo(1))
+	}, "api")
+	report := newTestReport(server.URL)
+	report.PathFilter = []string{"docs/*.md", "services/api"}
+	if err := report.Run(); err != nil {
+		t.Fatal(err)
+	}
+	merged := report.Result.MergedPRs
+	if got := numbers(merged); got != "[1 2]" {
+		t.Fatalf("MergedPRs = %s, want [1 2]", got)
+	}
+	if !reflect.DeepEqual([]string(merged[0].ChangedFiles), []string{"README.md", "docs/setup.md"}) {
+		t.Errorf("ChangedFiles = %v, want [README.md docs/setup.md]", merged[0].ChangedFiles)
+	}
+}
+
+func TestRunSkipsInactiveRepositories(t *testing.T) {
+	var reported []string
+	server := newGraphQLServer(t, func(req graphQLRequest, w http.ResponseWriter) {
+		if isListing(req) {
+			fmt.Fprintf(w, `{"data":{"organization":{"repositories":{"nodes":[
+				{"name":"active","pushedAt":%q},{"name":"stale","pushedAt":%q},{"name":"unknown"}],"totalCount":3}}}}`,
+				daysAgo(1), daysAgo(60))
+			return
+		}
+		repo, _ := req.Variables["repo"].(string)
+		reported = append(reported, repo)
+		fmt.Fprint(w, repositoryJSON(repo, ""))
+	})
+	report := newTestReport(server.URL)
+	report.Concurrency = 1
+	report.SkipInactiveRepos = true
+	if err := report.Run(); err != nil {
+		t.Fatal(err)
+	}
+	// Repositories with an unknown push date are considered active
+	if !reflect.DeepEqual(reported, []string{"active", "unknown"}) {
+		t.Fatalf("reported %v, want [active unknown]", reported)
+	}
+}
+
+func TestRunFiltersRepositoriesByVisibility(t *testing.T) {
+	server := newGraphQLServer(t, func(req graphQLRequest, w http.ResponseWriter) {
+		if isListing(req) {
+			fmt.Fprint(w, `{"data":{"organization":{"repositories":{"nodes":[
+				{"name":"site","visibility":"PUBLIC"},{"name":"secret","visibility":"PRIVATE"},{"name":"tools","visibility":"INTERNAL"}],"totalCount":3}}}}`)
+			return
+		}
+		repo, _ := req.Variables["repo"].(string)
+		fmt.Fprint(w, repositoryJSON(repo, fmt.Sprintf(`"mergedPR":{"nodes":[{"number":1,"mergedAt":%q}]}`, daysAgo(1))))
+	})
+	for visibility, want := range map[string]string{
+		VisibilityPublic:  "[site]",
+		VisibilityPrivate: "[secret]",
+		VisibilityAll:     "[secret site tools]",
+		"":                "[secret site tools]",
+	} {
+		report := newTestReport(server.URL)
+		report.Visibility = visibility
+		if err := report.Run(); err != nil {
+			t.Fatal(err)
+		}
+		reported := []string{}
+		for _, pr := range report.Result.MergedPRs {
+			reported = append(reported, pr.Repository)
+		}
+		sort.Strings(reported)
+		if fmt.Sprint(reported) != want {
+			t.Errorf("Visibility %q reported %v, want %s", visibility, reported, want)
+		}
+	}
+}
+
+func TestValidateRejectsUnknownVisibility(t *testing.T) {
+	report := NewActivityReport("acme", "token", 7)
+	report.Visibility = "SECRET"
+	if err := report.Validate(); err == nil {
+		t.Fatal("Validate() accepted an unknown visibility")
+	}
+}
+
+func TestRunListsRepositoriesOfUserAccount(t *testing.T) {
+	var listings []string
+	server := newGraphQLServer(t, func(req graphQLRequest, w http.ResponseWriter) {
+		if isListing(req) {
+			listings = append(listings, req.Query)
+			fmt.Fprint(w, `{"data":{"user":{"repositories":{"nodes":[{"name":"dotfiles"}],"totalCount":1}}}}`)
+			return
+		}
+		repo, _ := req.Variables["repo"].(string)
+		fmt.Fprint(w, repositoryJSON(repo, fmt.Sprintf(`"mergedPR":{"nodes":[{"number":1,"mergedAt":%q}]}`, daysAgo(1))))
+	})
+	for _, fullScan := range []bool{true, false} {
+		listings = nil
+		report := newTestReport(server.URL)
+		report.Organization = "alice"
+		report.AccountType = AccountUser
+		report.FullScan = fullScan
+		if err := report.Run(); err != nil {
+			t.Fatalf("FullScan %v: %v", fullScan, err)
+		}
+		if len(report.Result.MergedPRs) != 1 || report.Result.MergedPRs[0].Repository != "dotfiles" {
+			t.Errorf("FullScan %v: MergedPRs = %+v, want #1 of dotfiles", fullScan, report.Result.MergedPRs)
+		}
+		for _, query := range listings {
+			if !strings.Contains(query, "user(login:$organization)") || strings.Contains(query, "organization(login:") {
+				t.Errorf("FullScan %v: listing %q does not query the user account", fullScan, query)
+			}
+		}
+	}
+}
+
+func TestValidateRejectsUnknownAccountType(t *testing.T) {
+	report := NewActivityReport("acme", "token", 7)
+	report.AccountType = "TEAM"
+	if err := report.Validate(); err == nil || !strings.Contains(err.Error(), "AccountType") {
+		t.Errorf("Validate() = %v, want an AccountType error", err)
+	}
+}
+
+func TestRunExcludesBots(t *testing.T) {
+	server := newRepositoryServer(t, func(repo string) string {
+		return fmt.Sprintf(`"mergedPR":{"nodes":[
+			{"number":1,"mergedAt":%[1]q,"author":{"login":"dependabot[bot]"},
+				"participants":{"totalCount":3,"nodes":[{"login":"dependabot[bot]"},{"login":"alice"},{"login":"ci-robot"}]}},
+			{"number":2,"mergedAt":%[1]q,"author":{"login":"alice"},
+				"participants":{"totalCount":1,"nodes":[{"login":"alice"}]}}]}`, daysAgo(1))
+	}, "api")
+	for _, exclude := range []bool{false, true} {
+		report := newTestReport(server.URL)
+		report.ExcludeBots = exclude
+		report.BotPatterns = []string{"*-robot"}
+		if err := report.Run(); err != nil {
+			t.Fatal(err)
+		}
+		participants := fmt.Sprint(report.Result.DistinctParticipants())
+		authors := fmt.Sprint(report.Result.AuthorStats())
+		if exclude {
+			if participants != "[alice]" || authors != "map[alice:1]" {
+				t.Errorf("ExcludeBots: participants %s and authors %s, want only alice", participants, authors)
+			}
+			if count := report.Result.MergedPRs[0].Participants.TotalCount; count != 1 {
+				t.Errorf("ExcludeBots: participants count = %d, want 1", count)
+			}
+		} else if participants != "[alice ci-robot dependabot[bot]]" || authors != "map[alice:1 dependabot[bot]:1]" {
+			t.Errorf("participants %s and authors %s, want the bots too", participants, authors)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package ghreport
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// GraphQLError is returned when GitHub answers a query with GraphQL errors
+type GraphQLError struct {
+	// Messages lists the messages of every error returned by GitHub
+	Messages []string
+	// Types lists the type of each error, e.g. NOT_FOUND, empty when GitHub gives none
+	Types []string
+	// Partial tells whether GitHub returned data along with the errors
+	Partial bool
+}
+
+func (e *GraphQLError) Error() string {
+	return "GitHub returned GraphQL errors: " + strings.Join(e.Messages, "; ")
+}
+
+// graphQLErrors collects the GraphQL errors of a single query.
+// Decoded tells whether graphQLErrorsTransport read the response, which it doesn't with an
+// injected Client.
+type graphQLErrors struct {
+	Messages []string
+	Types    []string
+	HasData  bool
+	Decoded  bool
+}
+
+// graphQLErrorPrefix prefixes the errors returned by the GraphQL client
+const graphQLErrorPrefix = "graphql: "
+
+// graphQLClientError returns the *GraphQLError matching an error of the GraphQL client when it
+// reports a GraphQL error, which holds only the first message of the response
+func graphQLClientError(err error) (*GraphQLError, bool) {
+	message := err.Error()
+	if !strings.HasPrefix(message, graphQLErrorPrefix) || strings.HasPrefix(message, graphQLErrorPrefix+"server returned a non-200 status code") {
+		return nil, false
+	}
+	return &GraphQLError{Messages: []string{strings.TrimPrefix(message, graphQLErrorPrefix)}, Types: []string{""}}, true
+}
+
+// graphQLErrorsKey is the context key of the *graphQLErrors filled by graphQLErrorsTransport
+type graphQLErrorsKey struct{}
+
+// graphQLErrorsTransport records the GraphQL errors of each response in the *graphQLErrors
+// found in the request context. The GraphQL client only reports the first one.
+type graphQLErrorsTransport struct {
+	base http.RoundTripper
+}
+
+func (t *graphQLErrorsTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	errs, ok := req.Context().Value(graphQLErrorsKey{}).(*graphQLErrors)
+	if err != nil || !ok || resp.StatusCode != http.StatusOK {
+		return resp, err
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	var response struct {
+		Data   json.RawMessage
+		Errors []struct {
+			Message string
+			Type    string
+		}
+	}
+	if json.Unmarshal(body, &response) == nil {
+		errs.Decoded = true
+		errs.Messages = nil
+		errs.Types = nil
+		for _, graphErr := range response.Errors {
+			errs.Messages = append(errs.Messages, graphErr.Message)
+			errs.Types = append(errs.Types, graphErr.Type)
+		}
+		errs.HasData = len(response.Data) > 0 && string(response.Data) != "null"
+	}
+	return resp, nil
+}
+
+// checkGraphQLErrors returns the error of a query, replaced by a *GraphQLError listing every
+// GraphQL error when GitHub returned some. With AllowPartialData, errors returned along with
+// data are logged and nil is returned so the partial data is used.
+// When the response wasn't decoded by graphQLErrorsTransport (injected Client), the error of the
+// GraphQL client is converted instead: it holds the first message only, and no partial data.
+func (gr *ActivityReport) checkGraphQLErrors(err error, errs *graphQLErrors) error {
+	if !errs.Decoded && err != nil {
+		if graphQLErr, ok := graphQLClientError(err); ok {
+			return graphQLErr
+		}
+		return err
+	}
+	if len(errs.Messages) == 0 {
+		return err
+	}
+	graphQLErr := &GraphQLError{Messages: errs.Messages, Types: errs.Types, Partial: errs.HasData}
+	if graphQLErr.Partial && gr.AllowPartialData {
+		gr.warnf("Using partial data: %v\n", graphQLErr)
+		return nil
+	}
+	return graphQLErr
+}
+
+// withGraphQLErrors returns ctx carrying errs, to be filled by graphQLErrorsTransport
+func withGraphQLErrors(ctx context.Context, errs *graphQLErrors) context.Context {
+	return context.WithValue(ctx, graphQLErrorsKey{}, errs)
+}
@@ -0,0 +1,51 @@
+package ghreport
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+// newPartialDataServer starts a test server listing api, whose report carries a merged pull
+// request along with a GraphQL error
+func newPartialDataServer(t *testing.T) string {
+	server := newGraphQLServer(t, func(req graphQLRequest, w http.ResponseWriter) {
+		if isListing(req) {
+			fmt.Fprint(w, listingJSON("api"))
+			return
+		}
+		fmt.Fprintf(w, `{"data":{"repository":{"name":"api","mergedPR":{"nodes":[{"number":1,"mergedAt":%q}]}}},
+			"errors":[{"type":"FORBIDDEN","message":"Resource not accessible"},{"message":"Field too expensive"}]}`, daysAgo(1))
+	})
+	return server.URL
+}
+
+func TestRunFailsOnPartialDataByDefault(t *testing.T) {
+	report := newTestReport(newPartialDataServer(t))
+	err := report.Run()
+	var graphQLErr *GraphQLError
+	if !errors.As(err, &graphQLErr) {
+		t.Fatalf("Run() = %v, want a *GraphQLError", err)
+	}
+	if !graphQLErr.Partial || fmt.Sprint(graphQLErr.Messages) != "[Resource not accessible Field too expensive]" ||
+		fmt.Sprint(graphQLErr.Types) != "[FORBIDDEN ]" {
+		t.Fatalf("GraphQLError = %+v, want both partial errors", graphQLErr)
+	}
+}
+
+func TestRunUsesPartialDataWhenAllowed(t *testing.T) {
+	report := newTestReport(newPartialDataServer(t))
+	report.AllowPartialData = true
+	logger := &recordingLogger{}
+	report.Logger = logger
+	if err := report.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Result.MergedPRs) != 1 {
+		t.Fatalf("MergedPRs = %+v, want the partial data", report.Result.MergedPRs)
+	}
+	if !containsMessage(logger.messages, "WARN Using partial data: GitHub returned GraphQL errors: Resource not accessible; Field too expensive") {
+		t.Fatalf("Logger messages = %q, want a warning with the errors", logger.messages)
+	}
+}
@@ -0,0 +1,82 @@
+package ghreport
+
+import (
+	"html/template"
+	"io"
+	"strings"
+)
+
+// htmlTemplate renders the report as a self-contained HTML page.
+// Styles are inlined so that the page renders correctly in email clients.
+var htmlTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"prURL":        func(gr *ActivityReport, pr PRStruct) string { return gr.PullRequestURL(pr) },
+	"participants": participantLogins,
+	"date":         func(gr *ActivityReport, iso string) string { return gr.humanDate(iso) },
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Activity report for {{.Organizations}}</title>
+</head>
+<body style="font-family: Helvetica, Arial, sans-serif; color: #24292e; margin: 16px;">
+<h1 style="font-size: 24px; margin: 0 0 8px 0;">Activity report for {{.Organizations}}</h1>
+<p style="color: #586069; margin: 0 0 16px 0;">From {{.Since}} to {{.Until}} &middot; merged {{len .Result.MergedPRs}}, open with activity {{len .Result.OpenPRsWithActivity}}, open without activity {{len .Result.OpenPRsWithoutActivity}}</p>
+{{- range .Sections}}
+<h2 style="font-size: 18px; border-bottom: 1px solid #e1e4e8; padding-bottom: 4px;">{{.Title}}</h2>
+{{- if .PullRequests}}
+<table style="border-collapse: collapse; width: 100%; font-size: 14px;">
+<tr>
+<th style="text-align: left; padding: 4px 8px; background: #f6f8fa; border: 1px solid #e1e4e8;">Repository</th>
+<th style="text-align: left; padding: 4px 8px; background: #f6f8fa; border: 1px solid #e1e4e8;">PR</th>
+<th style="text-align: left; padding: 4px 8px; background: #f6f8fa; border: 1px solid #e1e4e8;">Title</th>
+<th style="text-align: left; padding: 4px 8px; background: #f6f8fa; border: 1px solid #e1e4e8;">Created</th>
+<th style="text-align: left; padding: 4px 8px; background: #f6f8fa; border: 1px solid #e1e4e8;">Participants</th>
+</tr>
+{{- range .PullRequests}}
+<tr>
+<td style="padding: 4px 8px; border: 1px solid #e1e4e8;">{{.Repository}}</td>
+<td style="padding: 4px 8px; border: 1px solid #e1e4e8;"><a href="{{prURL $.Report .}}" style="color: #0366d6;">#{{.Number}}</a></td>
+<td style="padding: 4px 8px; border: 1px solid #e1e4e8;">{{.Title}}</td>
+<td style="padding: 4px 8px; border: 1px solid #e1e4e8;">{{date $.Report .CreatedAt}}</td>
+<td style="padding: 4px 8px; border: 1px solid #e1e4e8;">{{participants .}}</td>
+</tr>
+{{- end}}
+</table>
+{{- else}}
+<p style="color: #586069;">None</p>
+{{- end}}
+{{- end}}
+</body>
+</html>
+`))
+
+// htmlSection is a titled list of pull requests rendered by htmlTemplate
+type htmlSection struct {
+	Title        string
+	PullRequests []PRStruct
+}
+
+// RenderHTML writes the report as a self-contained HTML page suitable for email
+func (gr *ActivityReport) RenderHTML(w io.Writer) error {
+	result := gr.exportResult()
+	since, until := gr.window(result.ReportDate)
+	return htmlTemplate.Execute(w, struct {
+		Report        *ActivityReport
+		Result        Result
+		Organizations string
+		Since         string
+		Until         string
+		Sections      []htmlSection
+	}{
+		Report:        gr,
+		Result:        result,
+		Organizations: strings.Join(gr.organizations(), ", "),
+		Since:         since.Format(HUMAN_FORM),
+		Until:         until.Format(HUMAN_FORM),
+		Sections: []htmlSection{
+			{"Merged this period", result.MergedPRs},
+			{"Open with activity", result.OpenPRsWithActivity},
+			{"Open without activity", result.OpenPRsWithoutActivity},
+		},
+	})
+}
@@ -0,0 +1,21 @@
+package ghreport
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestRenderHTML(t *testing.T) {
+	report := NewActivityReport("acme", "token", 7)
+	report.Result.ReportDate = time.Date(2020, 1, 8, 9, 30, 0, 0, time.UTC)
+	report.Result.MergedPRs = []PRStruct{{Repository: "api", Number: 12, Title: "Escape <script> & friends", CreatedAt: "2020-01-02T10:00:00Z"}}
+	report.Result.MergedPRs[0].Participants.Nodes = []UserStruct{{Login: "alice"}, {Login: "bob"}}
+	report.Result.OpenPRsWithActivity = []PRStruct{{Repository: "web", Number: 3, Title: "Redesign", CreatedAt: "2019-12-20T08:15:00Z"}}
+
+	var buf bytes.Buffer
+	if err := report.RenderHTML(&buf); err != nil {
+		t.Fatal(err)
+	}
+	checkGolden(t, "testdata/report.html", buf.Bytes())
+}
@@ -0,0 +1,106 @@
+package ghreport
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"time"
+)
+
+// activityReportJSON defines the JSON document produced for an ActivityReport.
+// Keys are part of the public output format and must stay stable.
+type activityReportJSON struct {
+	Organization           string        `json:"organization"`
+	Organizations          []string      `json:"organizations"`
+	ReportDate             time.Time     `json:"reportDate"`
+	Duration               int           `json:"duration"`
+	MergedPRs              []PRStruct    `json:"mergedPRs"`
+	OpenPRsWithActivity    []PRStruct    `json:"openPRsWithActivity"`
+	OpenPRsWithoutActivity []PRStruct    `json:"openPRsWithoutActivity"`
+	ClosedPRs              []PRStruct    `json:"closedPRs"`
+	DraftPRs               []PRStruct    `json:"draftPRs"`
+	StalePRs               []PRStruct    `json:"stalePRs"`
+	ConflictingPRs         []PRStruct    `json:"conflictingPRs"`
+	Issues                 []IssueStruct `json:"issues"`
+}
+
+// nonNilPRs returns an empty slice instead of nil so that JSON output contains [] rather than null
+func nonNilPRs(prs []PRStruct) []PRStruct {
+	if prs == nil {
+		return []PRStruct{}
+	}
+	return prs
+}
+
+// nonNilIssues returns an empty slice instead of nil so that JSON output contains [] rather than null
+func nonNilIssues(issues []IssueStruct) []IssueStruct {
+	if issues == nil {
+		return []IssueStruct{}
+	}
+	return issues
+}
+
+// MarshalJSON encodes the report parameters and its result as a JSON document
+func (gr *ActivityReport) MarshalJSON() ([]byte, error) {
+	result := gr.exportResult()
+	return json.Marshal(activityReportJSON{
+		Organization:           gr.Organization,
+		Organizations:          gr.organizations(),
+		ReportDate:             gr.ReportDate,
+		Duration:               gr.Duration,
+		MergedPRs:              nonNilPRs(result.MergedPRs),
+		OpenPRsWithActivity:    nonNilPRs(result.OpenPRsWithActivity),
+		OpenPRsWithoutActivity: nonNilPRs(result.OpenPRsWithoutActivity),
+		ClosedPRs:              nonNilPRs(result.ClosedPRs),
+		DraftPRs:               nonNilPRs(result.DraftPRs),
+		StalePRs:               nonNilPRs(result.StalePRs),
+		ConflictingPRs:         nonNilPRs(result.ConflictingPRs),
+		Issues:                 nonNilIssues(result.Issues),
+	})
+}
+
+// ToJSON writes the report as an indented JSON document to w
+func (gr *ActivityReport) ToJSON(w io.Writer) error {
+	data, err := gr.MarshalJSON()
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, data, "", "  "); err != nil {
+		return err
+	}
+	buf.WriteByte('\n')
+	_, err = buf.WriteTo(w)
+	return err
+}
+
+// jsonlPR is a line of the JSON Lines output: a pull request and the list it belongs to
+type jsonlPR struct {
+	Category string `json:"category"`
+	PRStruct
+}
+
+// WriteJSONL writes the pull requests of the report as JSON Lines, one JSON object per line.
+// Each object holds the pull request fields and a "category" field: merged, openWithActivity,
+// openWithoutActivity, closed or draft.
+func (gr *ActivityReport) WriteJSONL(w io.Writer) error {
+	result := gr.exportResult()
+	encoder := json.NewEncoder(w)
+	for _, category := range []struct {
+		name         string
+		pullrequests []PRStruct
+	}{
+		{"merged", result.MergedPRs},
+		{"openWithActivity", result.OpenPRsWithActivity},
+		{"openWithoutActivity", result.OpenPRsWithoutActivity},
+		{"closed", result.ClosedPRs},
+		{"draft", result.DraftPRs},
+	} {
+		for _, pr := range category.pullrequests {
+			if err := encoder.Encode(jsonlPR{Category: category.name, PRStruct: pr}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
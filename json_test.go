@@ -0,0 +1,69 @@
+package ghreport
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestToJSON(t *testing.T) {
+	report := NewActivityReport("acme", "token", 7)
+	report.ReportDate = time.Date(2020, 1, 8, 0, 0, 0, 0, time.UTC)
+	report.Result.MergedPRs = []PRStruct{{Number: 1, Title: "Fix", Repository: "api"}}
+
+	var buf bytes.Buffer
+	if err := report.ToJSON(&buf); err != nil {
+		t.Fatal(err)
+	}
+	var decoded map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatalf("invalid JSON %q: %v", buf.String(), err)
+	}
+	if decoded["organization"] != "acme" || decoded["duration"] != float64(7) || decoded["reportDate"] != "2020-01-08T00:00:00Z" {
+		t.Fatalf("unexpected report parameters in %s", buf.String())
+	}
+	merged := decoded["mergedPRs"].([]interface{})
+	if len(merged) != 1 || merged[0].(map[string]interface{})["title"] != "Fix" {
+		t.Fatalf("mergedPRs = %v", merged)
+	}
+	// Empty lists are encoded as [] rather than null
+	for _, key := range []string{"openPRsWithActivity", "openPRsWithoutActivity", "closedPRs", "issues"} {
+		if list, ok := decoded[key].([]interface{}); !ok || len(list) != 0 {
+			t.Errorf("%s = %v, want []", key, decoded[key])
+		}
+	}
+	if !strings.HasSuffix(buf.String(), "\n") || !strings.Contains(buf.String(), "\n  \"organization\"") {
+		t.Errorf("output is not indented: %q", buf.String())
+	}
+}
+
+func TestWriteJSONL(t *testing.T) {
+	report := NewActivityReport("acme", "token", 7)
+	report.Result.MergedPRs = []PRStruct{{Number: 1, Title: "Fix", Repository: "api"}, {Number: 2, Repository: "api"}}
+	report.Result.OpenPRsWithActivity = []PRStruct{{Number: 3, Repository: "web"}}
+	report.Result.OpenPRsWithoutActivity = []PRStruct{{Number: 4, Repository: "web"}}
+
+	var buf bytes.Buffer
+	if err := report.WriteJSONL(&buf); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("expected 4 lines, got %d: %q", len(lines), buf.String())
+	}
+	expected := []struct {
+		number   float64
+		category string
+	}{{1, "merged"}, {2, "merged"}, {3, "openWithActivity"}, {4, "openWithoutActivity"}}
+	for i, line := range lines {
+		var decoded map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &decoded); err != nil {
+			t.Fatalf("line %d is not valid JSON %q: %v", i, line, err)
+		}
+		if decoded["number"] != expected[i].number || decoded["category"] != expected[i].category {
+			t.Errorf("line %d = %s, want #%v in %s", i, line, expected[i].number, expected[i].category)
+		}
+	}
+}
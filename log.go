@@ -0,0 +1,87 @@
+package ghreport
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Logger receives leveled log messages. *slog.Logger satisfies this interface.
+type Logger interface {
+	Debug(msg string, args ...interface{})
+	Info(msg string, args ...interface{})
+	Warn(msg string, args ...interface{})
+}
+
+// logLevel identifies the severity of a log message
+type logLevel int
+
+const (
+	levelDebug logLevel = iota
+	levelInfo
+	levelWarn
+)
+
+// output sends a message to Log and Logger, never concurrently.
+// It does nothing when neither is set.
+func (gr *ActivityReport) output(level logLevel, format string, args ...interface{}) {
+	if gr.Log == nil && gr.Logger == nil {
+		return
+	}
+	gr.logMu.Lock()
+	defer gr.logMu.Unlock()
+	message := fmt.Sprintf(format, args...)
+	if gr.Log != nil {
+		if gr.RequestID != "" {
+			gr.Log("[" + gr.RequestID + "] " + message)
+		} else {
+			gr.Log(message)
+		}
+	}
+	if gr.Logger != nil {
+		message = strings.TrimSuffix(message, "\n")
+		attrs := []interface{}{}
+		if gr.RequestID != "" {
+			attrs = append(attrs, "requestID", gr.RequestID)
+		}
+		switch level {
+		case levelWarn:
+			gr.Logger.Warn(message, attrs...)
+		case levelInfo:
+			gr.Logger.Info(message, attrs...)
+		default:
+			gr.Logger.Debug(message, attrs...)
+		}
+	}
+}
+
+// requestIDTransport sets the X-Request-ID header of every request to the RequestID of the report
+type requestIDTransport struct {
+	base   http.RoundTripper
+	report *ActivityReport
+}
+
+func (t *requestIDTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.report.RequestID == "" {
+		return t.base.RoundTrip(req)
+	}
+	// A RoundTripper must not modify the request it is given
+	req = req.Clone(req.Context())
+	req.Header.Set("X-Request-ID", t.report.RequestID)
+	return t.base.RoundTrip(req)
+}
+
+// logf logs debug information
+func (gr *ActivityReport) logf(format string, args ...interface{}) {
+	gr.output(levelDebug, format, args...)
+}
+
+// infof logs the progress of the report
+func (gr *ActivityReport) infof(format string, args ...interface{}) {
+	gr.output(levelInfo, format, args...)
+}
+
+// warnf logs situations degrading the report, such as low rate limit credits
+func (gr *ActivityReport) warnf(format string, args ...interface{}) {
+	gr.output(levelWarn, format, args...)
+}
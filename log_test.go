@@ -0,0 +1,119 @@
+package ghreport
+
+import (
+	"bytes"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordingLogger is a Logger recording the messages it receives, prefixed by their level
+type recordingLogger struct {
+	mu       sync.Mutex
+	messages []string
+}
+
+func (l *recordingLogger) record(level string, msg string, args ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.messages = append(l.messages, level+" "+msg+fmt.Sprint(args...))
+}
+
+func (l *recordingLogger) Debug(msg string, args ...interface{}) { l.record("DEBUG", msg, args...) }
+func (l *recordingLogger) Info(msg string, args ...interface{})  { l.record("INFO", msg, args...) }
+func (l *recordingLogger) Warn(msg string, args ...interface{})  { l.record("WARN", msg, args...) }
+
+func TestRunLogsLowCreditsAsWarning(t *testing.T) {
+	var reports int32
+	logger := &recordingLogger{}
+	var logged []string
+	report := newTestReport(newRateLimitedServer(t, 3, time.Now(), &reports))
+	report.MinRemainingCredits = 10
+	report.WaitForRateLimitReset = true
+	report.Logger = logger
+	report.Log = func(s string) { logged = append(logged, s) }
+	report.Run()
+
+	if !containsMessage(logger.messages, "WARN Credits remaining 3") {
+		t.Errorf("Logger messages = %q, want a warning for the remaining credits", logger.messages)
+	}
+	if !containsMessage(logger.messages, "INFO Nb merged pr:0") {
+		t.Errorf("Logger messages = %q, want the counts as information", logger.messages)
+	}
+	if !containsMessage(logged, "Credits remaining 3\n") {
+		t.Errorf("Log messages = %q, want the remaining credits", logged)
+	}
+}
+
+func TestRunWithoutLog(t *testing.T) {
+	var reports int32
+	// The low credits make the report log a warning, with nowhere to send it
+	report := newTestReport(newRateLimitedServer(t, 3, time.Now().Add(time.Hour), &reports))
+	report.MinRemainingCredits = 10
+	report.Log = nil
+	report.Logger = nil
+	defer func() {
+		if r := recover(); r != nil {
+			t.Fatalf("Run panicked without Log: %v", r)
+		}
+	}()
+	report.Run()
+}
+
+func TestSlogLoggerIsALogger(t *testing.T) {
+	var buf bytes.Buffer
+	report := NewActivityReport("acme", "token", 7)
+	report.Logger = slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelWarn}))
+	report.RequestID = "run-42"
+	report.warnf("Credits remaining %v\n", 3)
+	report.logf("hidden\n")
+	if got := buf.String(); !strings.Contains(got, `level=WARN msg="Credits remaining 3" requestID=run-42`) || strings.Contains(got, "hidden") {
+		t.Fatalf("slog output = %q", got)
+	}
+}
+
+func TestRunTracesRequestID(t *testing.T) {
+	server, requestIDs := newHeaderServer(t, "X-Request-ID")
+	var mu sync.Mutex
+	var logged []string
+	report := newTestReport(server.URL)
+	report.RequestID = "run-42"
+	report.Log = func(s string) {
+		mu.Lock()
+		defer mu.Unlock()
+		logged = append(logged, s)
+	}
+	if err := report.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if len(logged) == 0 {
+		t.Fatal("nothing logged")
+	}
+	for _, message := range logged {
+		if !strings.HasPrefix(message, "[run-42] ") {
+			t.Errorf("message %q is not prefixed with the request ID", message)
+		}
+	}
+	sent := requestIDs()
+	if len(sent) == 0 {
+		t.Fatal("no request received")
+	}
+	for _, requestID := range sent {
+		if requestID != "run-42" {
+			t.Errorf("X-Request-ID = %q, want run-42", requestID)
+		}
+	}
+}
+
+// containsMessage reports whether one of the messages starts with prefix
+func containsMessage(messages []string, prefix string) bool {
+	for _, message := range messages {
+		if strings.HasPrefix(message, prefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,81 @@
+package ghreport
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+)
+
+// webURL returns the root URL of the GitHub web interface matching BaseURL
+func (gr *ActivityReport) webURL() string {
+	if gr.BaseURL == "" || gr.BaseURL == DefaultBaseURL {
+		return "https://github.com"
+	}
+	u, err := url.Parse(gr.BaseURL)
+	if err != nil || u.Host == "" {
+		return "https://github.com"
+	}
+	return u.Scheme + "://" + u.Host
+}
+
+// PullRequestURL returns the URL of the pull request on GitHub
+func (gr *ActivityReport) PullRequestURL(pr PRStruct) string {
+	org := pr.Org
+	if org == "" {
+		org = gr.Organization
+	}
+	return fmt.Sprintf("%s/%s/%s/pull/%d", gr.webURL(), org, pr.Repository, pr.Number)
+}
+
+// participantLogins returns the logins of the participants of a pull request separated by commas
+func participantLogins(pr PRStruct) string {
+	logins := make([]string, 0, len(pr.Participants.Nodes))
+	for _, user := range pr.Participants.Nodes {
+		logins = append(logins, user.Login)
+	}
+	return strings.Join(logins, ", ")
+}
+
+// markdownEscaper escapes characters that would break a Markdown table cell
+var markdownEscaper = strings.NewReplacer("|", "\\|", "\r", " ", "\n", " ")
+
+// RenderMarkdown writes the report as a Markdown document, with one table per section
+func (gr *ActivityReport) RenderMarkdown(w io.Writer) error {
+	result := gr.exportResult()
+	since, until := gr.window(result.ReportDate)
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "# Activity report for %s\n\n", strings.Join(gr.organizations(), ", "))
+	fmt.Fprintf(&buf, "From %s to %s\n", since.Format(HUMAN_FORM), until.Format(HUMAN_FORM))
+
+	sections := []struct {
+		title        string
+		pullrequests []PRStruct
+	}{
+		{"Merged this period", result.MergedPRs},
+		{"Open with activity", result.OpenPRsWithActivity},
+		{"Open without activity", result.OpenPRsWithoutActivity},
+	}
+	for _, section := range sections {
+		fmt.Fprintf(&buf, "\n## %s\n\n", section.title)
+		if len(section.pullrequests) == 0 {
+			buf.WriteString("None\n")
+			continue
+		}
+		buf.WriteString("| Repository | PR | Title | Participants |\n")
+		buf.WriteString("|---|---|---|---|\n")
+		for _, pr := range section.pullrequests {
+			fmt.Fprintf(&buf, "| %s | [#%d](%s) | %s | %s |\n",
+				markdownEscaper.Replace(pr.Repository),
+				pr.Number,
+				gr.PullRequestURL(pr),
+				markdownEscaper.Replace(pr.Title),
+				markdownEscaper.Replace(participantLogins(pr)))
+		}
+	}
+
+	_, err := buf.WriteTo(w)
+	return err
+}
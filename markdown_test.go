@@ -0,0 +1,43 @@
+package ghreport
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestRenderMarkdown(t *testing.T) {
+	report := NewActivityReport("acme", "token", 7)
+	report.Result.ReportDate = time.Date(2020, 1, 8, 9, 30, 0, 0, time.UTC)
+	report.Result.MergedPRs = []PRStruct{{Repository: "api", Number: 12, Title: "Fix | pipes"}}
+	report.Result.MergedPRs[0].Participants.Nodes = []UserStruct{{Login: "alice"}, {Login: "bob"}}
+	report.Result.OpenPRsWithActivity = []PRStruct{{Org: "other", Repository: "web", Number: 3, Title: "Redesign"}}
+
+	var buf bytes.Buffer
+	if err := report.RenderMarkdown(&buf); err != nil {
+		t.Fatal(err)
+	}
+	checkGolden(t, "testdata/report.md", buf.Bytes())
+}
+
+// update rewrites the golden files with the current output: go test -run Golden -update
+var update = flag.Bool("update", false, "update the golden files")
+
+// checkGolden compares got with the content of the golden file
+func checkGolden(t *testing.T, golden string, got []byte) {
+	t.Helper()
+	if *update {
+		if err := os.WriteFile(golden, got, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatalf("output:\n%s\nwant (%s):\n%s", got, golden, want)
+	}
+}
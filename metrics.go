@@ -0,0 +1,176 @@
+package ghreport
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// ErrNoMergedPRs is returned by time-to-merge metrics when no merged pull request can be measured
+var ErrNoMergedPRs = errors.New("No merged pull request to measure")
+
+// SkippedPRsError reports pull requests ignored by a metric because their dates could not be parsed.
+// The metric returned along with this error is computed over the remaining pull requests.
+type SkippedPRsError struct {
+	Skipped int
+}
+
+func (e *SkippedPRsError) Error() string {
+	return fmt.Sprintf("%d pull requests skipped because of unparseable dates", e.Skipped)
+}
+
+// timesToMerge returns the time between creation and merge of each merged pull request,
+// and the number of pull requests skipped because of unparseable dates
+func (r *Result) timesToMerge() ([]time.Duration, int) {
+	durations := []time.Duration{}
+	skipped := 0
+	for _, pr := range r.MergedPRs {
+		created, err1 := time.Parse(ISO_FORM, pr.CreatedAt)
+		merged, err2 := time.Parse(ISO_FORM, pr.MergedAt)
+		if err1 != nil || err2 != nil {
+			skipped++
+			continue
+		}
+		durations = append(durations, merged.Sub(created))
+	}
+	return durations, skipped
+}
+
+// metricError returns the error to report along with a time-to-merge metric
+func metricError(measured int, skipped int) error {
+	if measured == 0 {
+		return ErrNoMergedPRs
+	}
+	if skipped > 0 {
+		return &SkippedPRsError{Skipped: skipped}
+	}
+	return nil
+}
+
+// AverageTimeToMerge returns the mean time between creation and merge of the merged pull requests.
+// Pull requests with unparseable dates are skipped and reported with a *SkippedPRsError.
+func (r *Result) AverageTimeToMerge() (time.Duration, error) {
+	durations, skipped := r.timesToMerge()
+	if len(durations) == 0 {
+		return 0, metricError(0, skipped)
+	}
+	var total time.Duration
+	for _, d := range durations {
+		total += d
+	}
+	return total / time.Duration(len(durations)), metricError(len(durations), skipped)
+}
+
+// MedianTimeToMerge returns the median time between creation and merge of the merged pull requests.
+// Pull requests with unparseable dates are skipped and reported with a *SkippedPRsError.
+func (r *Result) MedianTimeToMerge() (time.Duration, error) {
+	durations, skipped := r.timesToMerge()
+	if len(durations) == 0 {
+		return 0, metricError(0, skipped)
+	}
+	sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+	middle := len(durations) / 2
+	median := durations[middle]
+	if len(durations)%2 == 0 {
+		median = (durations[middle-1] + durations[middle]) / 2
+	}
+	return median, metricError(len(durations), skipped)
+}
+
+// AuthorStats returns the number of merged pull requests per author login.
+// Pull requests whose author is unknown (e.g. a deleted account) are not counted.
+func (r *Result) AuthorStats() map[string]int {
+	stats := map[string]int{}
+	for _, pr := range r.MergedPRs {
+		if pr.Author.Login != "" {
+			stats[pr.Author.Login]++
+		}
+	}
+	return stats
+}
+
+// ParticipationStats returns, per login, the number of pull requests of the result the user
+// authored or participated in. Each pull request counts once per user, even if it is listed
+// in several lists (e.g. both stale and without activity).
+func (r *Result) ParticipationStats() map[string]int {
+	stats := map[string]int{}
+	seen := map[prKey]bool{}
+	for _, pullrequests := range [][]PRStruct{r.MergedPRs, r.OpenPRsWithActivity, r.OpenPRsWithoutActivity, r.ClosedPRs, r.DraftPRs, r.StalePRs, r.ConflictingPRs} {
+		for _, pr := range pullrequests {
+			if seen[keyOf(pr)] {
+				continue
+			}
+			seen[keyOf(pr)] = true
+			logins := map[string]bool{}
+			if pr.Author.Login != "" {
+				logins[pr.Author.Login] = true
+			}
+			for _, user := range pr.Participants.Nodes {
+				if user.Login != "" {
+					logins[user.Login] = true
+				}
+			}
+			for login := range logins {
+				stats[login]++
+			}
+		}
+	}
+	return stats
+}
+
+// DistinctParticipants returns the sorted logins of the users participating in the merged
+// and open pull requests, each login once
+func (r *Result) DistinctParticipants() []string {
+	seen := map[string]bool{}
+	logins := []string{}
+	for _, pullrequests := range [][]PRStruct{r.MergedPRs, r.OpenPRsWithActivity, r.OpenPRsWithoutActivity} {
+		for _, pr := range pullrequests {
+			for _, user := range pr.Participants.Nodes {
+				if user.Login != "" && !seen[user.Login] {
+					seen[user.Login] = true
+					logins = append(logins, user.Login)
+				}
+			}
+		}
+	}
+	sort.Strings(logins)
+	return logins
+}
+
+// CommitTypeBreakdown returns the number of commits of the window by conventional-commit type
+// (feat, fix, chore...), across all repositories. Messages without a conventional prefix
+// are counted as "unknown".
+func (r *Result) CommitTypeBreakdown() map[string]int {
+	breakdown := map[string]int{}
+	for _, summary := range r.Commits {
+		for commitType, count := range summary.Types {
+			breakdown[commitType] += count
+		}
+	}
+	return breakdown
+}
+
+// LabelBreakdown returns the number of pull requests of the result carrying each label.
+// A pull request with several labels counts once for each of them, and each pull request
+// counts once even if it is listed in several lists (e.g. both stale and without activity).
+func (r *Result) LabelBreakdown() map[string]int {
+	breakdown := map[string]int{}
+	seen := map[prKey]bool{}
+	for _, pullrequests := range [][]PRStruct{r.MergedPRs, r.OpenPRsWithActivity, r.OpenPRsWithoutActivity, r.ClosedPRs, r.DraftPRs, r.StalePRs, r.ConflictingPRs} {
+		for _, pr := range pullrequests {
+			if seen[keyOf(pr)] {
+				continue
+			}
+			seen[keyOf(pr)] = true
+			labels := map[string]bool{}
+			for _, label := range pr.Labels {
+				labels[label] = true
+			}
+			for label := range labels {
+				breakdown[label]++
+			}
+		}
+	}
+	return breakdown
+}
@@ -0,0 +1,109 @@
+package ghreport
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestTimeToMerge(t *testing.T) {
+	r := &Result{MergedPRs: []PRStruct{
+		{CreatedAt: "2020-01-01T00:00:00Z", MergedAt: "2020-01-01T02:00:00Z"},
+		{CreatedAt: "2020-01-01T00:00:00Z", MergedAt: "2020-01-01T04:00:00Z"},
+		{CreatedAt: "2020-01-01T00:00:00Z", MergedAt: "2020-01-01T12:00:00Z"},
+		{CreatedAt: "not a date", MergedAt: "2020-01-01T12:00:00Z"},
+	}}
+
+	average, err := r.AverageTimeToMerge()
+	if average != 6*time.Hour {
+		t.Errorf("AverageTimeToMerge() = %v, want 6h", average)
+	}
+	var skipped *SkippedPRsError
+	if !errors.As(err, &skipped) || skipped.Skipped != 1 {
+		t.Errorf("AverageTimeToMerge() error = %v, want 1 skipped", err)
+	}
+
+	median, err := r.MedianTimeToMerge()
+	if median != 4*time.Hour {
+		t.Errorf("MedianTimeToMerge() = %v, want 4h", median)
+	}
+	if !errors.As(err, &skipped) {
+		t.Errorf("MedianTimeToMerge() error = %v, want a *SkippedPRsError", err)
+	}
+
+	r.MergedPRs = r.MergedPRs[:2]
+	if median, err := r.MedianTimeToMerge(); median != 3*time.Hour || err != nil {
+		t.Errorf("MedianTimeToMerge() = %v, %v, want 3h, nil", median, err)
+	}
+}
+
+func TestTimeToMergeWithoutMergedPRs(t *testing.T) {
+	if _, err := (&Result{}).AverageTimeToMerge(); err != ErrNoMergedPRs {
+		t.Errorf("AverageTimeToMerge() error = %v, want ErrNoMergedPRs", err)
+	}
+}
+
+func TestAuthorAndParticipationStats(t *testing.T) {
+	pr := func(number int, author string, participants ...string) PRStruct {
+		p := PRStruct{Org: "acme", Repository: "api", Number: number, Author: UserStruct{Login: author}}
+		for _, login := range participants {
+			p.Participants.Nodes = append(p.Participants.Nodes, UserStruct{Login: login})
+		}
+		return p
+	}
+	r := &Result{
+		MergedPRs:              []PRStruct{pr(1, "alice", "alice", "bob"), pr(2, "alice"), pr(3, "bob", "carol"), pr(4, "")},
+		OpenPRsWithActivity:    []PRStruct{pr(5, "carol", "alice")},
+		OpenPRsWithoutActivity: []PRStruct{pr(6, "dave")},
+		// A pull request listed twice is counted once
+		StalePRs: []PRStruct{pr(6, "dave")},
+	}
+	if got := fmt.Sprint(r.AuthorStats()); got != "map[alice:2 bob:1]" {
+		t.Errorf("AuthorStats() = %s, want map[alice:2 bob:1]", got)
+	}
+	if got := fmt.Sprint(r.ParticipationStats()); got != "map[alice:3 bob:2 carol:2 dave:1]" {
+		t.Errorf("ParticipationStats() = %s, want map[alice:3 bob:2 carol:2 dave:1]", got)
+	}
+}
+
+func TestDistinctParticipants(t *testing.T) {
+	pr := func(participants ...string) PRStruct {
+		p := PRStruct{}
+		for _, login := range participants {
+			p.Participants.Nodes = append(p.Participants.Nodes, UserStruct{Login: login})
+		}
+		return p
+	}
+	r := &Result{
+		MergedPRs:              []PRStruct{pr("carol", "alice"), pr("alice", "")},
+		OpenPRsWithActivity:    []PRStruct{pr("bob", "carol")},
+		OpenPRsWithoutActivity: []PRStruct{pr("alice")},
+		// Only the merged and open pull requests are considered
+		ClosedPRs: []PRStruct{pr("dave")},
+	}
+	if got := fmt.Sprint(r.DistinctParticipants()); got != "[alice bob carol]" {
+		t.Errorf("DistinctParticipants() = %s, want [alice bob carol]", got)
+	}
+	if got := (&Result{}).DistinctParticipants(); got == nil || len(got) != 0 {
+		t.Errorf("DistinctParticipants() of an empty result = %#v, want an empty list", got)
+	}
+}
+
+func TestLabelBreakdown(t *testing.T) {
+	pr := func(number int, labels ...string) PRStruct {
+		return PRStruct{Org: "acme", Repository: "api", Number: number, Labels: labels}
+	}
+	r := &Result{
+		MergedPRs:              []PRStruct{pr(1, "bug", "ui"), pr(2, "bug")},
+		OpenPRsWithActivity:    []PRStruct{pr(3, "needs-review", "bug")},
+		OpenPRsWithoutActivity: []PRStruct{pr(4), pr(5, "needs-review", "needs-review")},
+		ClosedPRs:              []PRStruct{pr(6, "wontfix")},
+		// A pull request listed twice is counted once
+		StalePRs: []PRStruct{pr(5, "needs-review", "needs-review")},
+	}
+	expected := "map[bug:3 needs-review:2 ui:1 wontfix:1]"
+	if got := fmt.Sprint(r.LabelBreakdown()); got != expected {
+		t.Errorf("LabelBreakdown() = %s, want %s", got, expected)
+	}
+}
@@ -0,0 +1,59 @@
+package ghreport
+
+import (
+	"net/http"
+)
+
+// DefaultDuration is the number of days covered by a report built with New by default
+const DefaultDuration = 7
+
+// Option configures an ActivityReport built with New
+type Option func(*ActivityReport)
+
+// New makes a new Report for org authenticated with token, configured by opts, e.g.:
+//
+//	report := ghreport.New("acme", token, ghreport.WithDuration(14), ghreport.WithConcurrency(8))
+//
+// Options not given keep the defaults of NewActivityReport, with a Duration of DefaultDuration.
+func New(org string, token string, opts ...Option) *ActivityReport {
+	report := NewActivityReport(org, token, DefaultDuration)
+	for _, opt := range opts {
+		opt(report)
+	}
+	return report
+}
+
+// WithDuration sets the number of days covered by the report
+func WithDuration(days int) Option {
+	return func(gr *ActivityReport) {
+		gr.Duration = days
+	}
+}
+
+// WithPageSize sets the number of items requested per page
+func WithPageSize(size int) Option {
+	return func(gr *ActivityReport) {
+		gr.PageSize = size
+	}
+}
+
+// WithConcurrency sets the number of repositories queried in parallel
+func WithConcurrency(concurrency int) Option {
+	return func(gr *ActivityReport) {
+		gr.Concurrency = concurrency
+	}
+}
+
+// WithBaseURL sets the GraphQL endpoint to query, e.g. for GitHub Enterprise
+func WithBaseURL(url string) Option {
+	return func(gr *ActivityReport) {
+		gr.BaseURL = url
+	}
+}
+
+// WithHTTPClient sets the HTTP client used to query GitHub. It must handle authentication itself.
+func WithHTTPClient(client *http.Client) Option {
+	return func(gr *ActivityReport) {
+		gr.HTTPClient = client
+	}
+}
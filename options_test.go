@@ -0,0 +1,43 @@
+package ghreport
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestNewAppliesOptions(t *testing.T) {
+	client := &http.Client{}
+	report := New("acme", "token",
+		WithDuration(14),
+		WithPageSize(20),
+		WithConcurrency(8),
+		WithBaseURL("https://github.example.com/api/graphql"),
+		WithHTTPClient(client))
+	if report.Duration != 14 {
+		t.Errorf("Duration = %d, want 14", report.Duration)
+	}
+	if report.PageSize != 20 {
+		t.Errorf("PageSize = %d, want 20", report.PageSize)
+	}
+	if report.Concurrency != 8 {
+		t.Errorf("Concurrency = %d, want 8", report.Concurrency)
+	}
+	if report.BaseURL != "https://github.example.com/api/graphql" {
+		t.Errorf("BaseURL = %q", report.BaseURL)
+	}
+	if report.HTTPClient != client {
+		t.Error("HTTPClient not set")
+	}
+	if err := report.Validate(); err != nil {
+		t.Errorf("Validate() = %v", err)
+	}
+}
+
+func TestNewKeepsDefaults(t *testing.T) {
+	report := New("acme", "token")
+	defaults := NewActivityReport("acme", "token", DefaultDuration)
+	if report.Organization != "acme" || report.Duration != DefaultDuration || report.PageSize != defaults.PageSize ||
+		report.Concurrency != defaults.Concurrency || report.BaseURL != defaults.BaseURL || report.HTTPClient != nil {
+		t.Errorf("New() = %+v, want the defaults of NewActivityReport", report)
+	}
+}
@@ -0,0 +1,11 @@
+module github.com/dsciamma/ghreport/promreport
+
+go 1.25.0
+
+require (
+	github.com/dsciamma/ghreport v0.0.0-00010101000000-000000000000
+	github.com/prometheus/client_golang v1.24.1
+)
+
+// promreport is developed alongside ghreport, in the same repository
+replace github.com/dsciamma/ghreport => ../
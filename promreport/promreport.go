@@ -0,0 +1,68 @@
+// Package promreport exposes the counts of a ghreport.ActivityReport as Prometheus gauges.
+// It is a separate module (see its go.mod) so that ghreport doesn't depend on the Prometheus client.
+package promreport
+
+import (
+	"github.com/dsciamma/ghreport"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Metrics holds the gauges updated from a report
+type Metrics struct {
+	MergedPRs              prometheus.Gauge
+	OpenPRsWithActivity    prometheus.Gauge
+	OpenPRsWithoutActivity prometheus.Gauge
+	RateLimitRemaining     prometheus.Gauge
+}
+
+// NewMetrics makes the gauges of a report, named <namespace>_merged_prs,
+// <namespace>_open_prs_with_activity, <namespace>_open_prs_without_activity and
+// <namespace>_rate_limit_remaining. They still have to be registered, see Register.
+func NewMetrics(namespace string) *Metrics {
+	return &Metrics{
+		MergedPRs: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "merged_prs",
+			Help:      "Number of pull requests merged during the report window.",
+		}),
+		OpenPRsWithActivity: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "open_prs_with_activity",
+			Help:      "Number of open pull requests with activity during the report window.",
+		}),
+		OpenPRsWithoutActivity: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "open_prs_without_activity",
+			Help:      "Number of open pull requests without activity during the report window.",
+		}),
+		RateLimitRemaining: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "rate_limit_remaining",
+			Help:      "GitHub GraphQL credits remaining after the last query.",
+		}),
+	}
+}
+
+// Register registers every gauge with registerer, e.g. prometheus.DefaultRegisterer
+func (m *Metrics) Register(registerer prometheus.Registerer) error {
+	for _, collector := range []prometheus.Collector{
+		m.MergedPRs,
+		m.OpenPRsWithActivity,
+		m.OpenPRsWithoutActivity,
+		m.RateLimitRemaining,
+	} {
+		if err := registerer.Register(collector); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Update sets the gauges from the Result and LastRateLimit of report.
+// It is meant to be called after each call to report.Run.
+func (m *Metrics) Update(report *ghreport.ActivityReport) {
+	m.MergedPRs.Set(float64(len(report.Result.MergedPRs)))
+	m.OpenPRsWithActivity.Set(float64(len(report.Result.OpenPRsWithActivity)))
+	m.OpenPRsWithoutActivity.Set(float64(len(report.Result.OpenPRsWithoutActivity)))
+	m.RateLimitRemaining.Set(float64(report.LastRateLimit.Remaining))
+}
@@ -0,0 +1,73 @@
+package promreport
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dsciamma/ghreport"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// newGitHubServer starts a test server listing the repository api, with two merged pull
+// requests and an open one with activity, and 4321 credits remaining
+func newGitHubServer(t *testing.T) *httptest.Server {
+	recent := time.Now().Add(-24 * time.Hour).UTC().Format(ghreport.ISO_FORM)
+	rateLimit := `"rateLimit":{"limit":5000,"cost":1,"remaining":4321,"resetAt":"2030-01-01T00:00:00Z"}`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if strings.Contains(string(body), "repositories(") {
+			fmt.Fprintf(w, `{"data":{"organization":{"repositories":{"nodes":[{"name":"api"}]}},%s}}`, rateLimit)
+			return
+		}
+		fmt.Fprintf(w, `{"data":{"repository":{"name":"api",
+			"mergedPR":{"nodes":[{"number":1,"mergedAt":%[1]q},{"number":2,"mergedAt":%[1]q}]},
+			"openPR":{"nodes":[{"number":3,"state":"OPEN","createdAt":%[1]q,"activity":{"totalCount":2}}]}},%[2]s}}`,
+			recent, rateLimit)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestUpdateSetsGaugesFromReport(t *testing.T) {
+	report := ghreport.NewActivityReport("acme", "token", 7)
+	report.BaseURL = newGitHubServer(t).URL
+	if err := report.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	metrics := NewMetrics("ghreport")
+	if err := metrics.Register(prometheus.NewRegistry()); err != nil {
+		t.Fatal(err)
+	}
+	metrics.Update(report)
+	for name, test := range map[string]struct {
+		gauge    prometheus.Gauge
+		expected float64
+	}{
+		"merged_prs":                {metrics.MergedPRs, 2},
+		"open_prs_with_activity":    {metrics.OpenPRsWithActivity, 1},
+		"open_prs_without_activity": {metrics.OpenPRsWithoutActivity, 0},
+		"rate_limit_remaining":      {metrics.RateLimitRemaining, 4321},
+	} {
+		if got := testutil.ToFloat64(test.gauge); got != test.expected {
+			t.Errorf("%s = %v, want %v", name, got, test.expected)
+		}
+	}
+}
+
+func TestRegisterTwiceFails(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	metrics := NewMetrics("ghreport")
+	if err := metrics.Register(registry); err != nil {
+		t.Fatal(err)
+	}
+	if err := metrics.Register(registry); err == nil {
+		t.Error("expected registering the same gauges twice to fail")
+	}
+}
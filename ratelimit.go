@@ -0,0 +1,186 @@
+package ghreport
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/dsciamma/graphql"
+)
+
+// ErrRateLimitExhausted is returned when the remaining GitHub credits fall below MinRemainingCredits
+var ErrRateLimitExhausted = errors.New("GitHub rate limit nearly exhausted")
+
+// RateLimit holds the GitHub rate limit state returned by a query
+type RateLimit struct {
+	Limit     int
+	Cost      int
+	Remaining int
+	ResetAt   time.Time
+}
+
+// recordRateLimit keeps the rate limit returned by the last query in LastRateLimit
+func (gr *ActivityReport) recordRateLimit(rateLimit RateLimitStruct) {
+	resetAt, _ := time.Parse(ISO_FORM, rateLimit.ResetAt)
+	gr.rateLimitMu.Lock()
+	gr.LastRateLimit = RateLimit{
+		Limit:     rateLimit.Limit,
+		Cost:      rateLimit.Cost,
+		Remaining: rateLimit.Remaining,
+		ResetAt:   resetAt,
+	}
+	gr.rateLimitSeen = true
+	gr.creditsUsed += rateLimit.Cost
+	gr.rateLimitMu.Unlock()
+	if rateLimit.Remaining < gr.MinRemainingCredits {
+		gr.warnf("Credits remaining %v\n", rateLimit.Remaining)
+	} else {
+		gr.logf("Credits remaining %v\n", rateLimit.Remaining)
+	}
+}
+
+// checkRateLimit is called before each repository query.
+// When the last observed remaining credits are below MinRemainingCredits, it either waits until
+// the rate limit is reset (WaitForRateLimitReset) or returns ErrRateLimitExhausted.
+func (gr *ActivityReport) checkRateLimit(ctx context.Context) error {
+	gr.rateLimitMu.Lock()
+	rateLimit, seen := gr.LastRateLimit, gr.rateLimitSeen
+	gr.rateLimitMu.Unlock()
+
+	if gr.MinRemainingCredits <= 0 || !seen || rateLimit.Remaining >= gr.MinRemainingCredits {
+		return nil
+	}
+	if rateLimit.ResetAt.IsZero() {
+		gr.warnf("Only %d credits remaining, stopping\n", rateLimit.Remaining)
+		return fmt.Errorf("%w: %d credits remaining", ErrRateLimitExhausted, rateLimit.Remaining)
+	}
+	if !gr.WaitForRateLimitReset {
+		gr.warnf("Only %d credits remaining until %v, stopping\n", rateLimit.Remaining, rateLimit.ResetAt)
+		return fmt.Errorf("%w: %d credits remaining until %v", ErrRateLimitExhausted, rateLimit.Remaining, rateLimit.ResetAt)
+	}
+
+	gr.warnf("Only %d credits remaining, waiting until %v\n", rateLimit.Remaining, rateLimit.ResetAt)
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(time.Until(rateLimit.ResetAt)):
+	}
+	gr.rateLimitMu.Lock()
+	gr.rateLimitSeen = false
+	gr.rateLimitMu.Unlock()
+	return nil
+}
+
+// pace waits until RequestInterval has elapsed since the previous query.
+// Concurrent callers are given successive slots, so it acts as a global limiter.
+func (gr *ActivityReport) pace(ctx context.Context) error {
+	if gr.RequestInterval <= 0 {
+		return nil
+	}
+	gr.paceMu.Lock()
+	now := time.Now()
+	slot := gr.nextRequestAt
+	if slot.Before(now) {
+		slot = now
+	}
+	gr.nextRequestAt = slot.Add(gr.RequestInterval)
+	gr.paceMu.Unlock()
+
+	wait := slot.Sub(now)
+	if wait <= 0 {
+		return nil
+	}
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(wait):
+		return nil
+	}
+}
+
+// usedCredits returns the total cost of the queries sent so far
+func (gr *ActivityReport) usedCredits() int {
+	gr.rateLimitMu.Lock()
+	defer gr.rateLimitMu.Unlock()
+	return gr.creditsUsed
+}
+
+// EstimateCost estimates the GraphQL credits a full run would spend without running it.
+// It lists the repositories, measures the cost of reporting one sample batch of BatchSize
+// repositories and returns listing cost + number of batches * sample cost. Cached listings are
+// counted at one credit per listing query, as they are spent again once the cache expires.
+//
+// With UseSearch, it runs the first page of each search instead and counts the cost of every page
+// of its results.
+func (gr *ActivityReport) EstimateCost(ctx context.Context) (int, error) {
+	if err := gr.Validate(); err != nil {
+		return 0, err
+	}
+	client := gr.newClient(ctx)
+	since, until := gr.window(time.Now())
+	if gr.UseSearch {
+		return gr.estimateSearchCost(ctx, client, since, until)
+	}
+
+	before := gr.usedCredits()
+	repositories, listing, err := gr.listOrganizationsRepositories(ctx, client, since)
+	if err != nil {
+		return 0, err
+	}
+	listingCost := gr.usedCredits() - before + listing.SavedQueries
+	if len(repositories) == 0 {
+		return listingCost, nil
+	}
+
+	batchSize := gr.BatchSize
+	if batchSize < 1 {
+		batchSize = 1
+	}
+	sample := repositories
+	if len(sample) > batchSize {
+		sample = sample[:batchSize]
+	}
+	before = gr.usedCredits()
+	if len(sample) == 1 {
+		if _, err := gr.reportRepository(ctx, client, sample[0].Organization, sample[0].Name, since); err != nil {
+			return 0, &RepositoryError{Organization: sample[0].Organization, Repository: sample[0].Name, Err: err}
+		}
+	} else if _, err := gr.reportBatch(ctx, client, sample, since); err != nil {
+		return 0, &RepositoryError{Organization: sample[0].Organization, Repository: sample[0].Name, Err: err}
+	}
+	batchCost := gr.usedCredits() - before
+	batches := (len(repositories) + batchSize - 1) / batchSize
+	gr.logf("Estimated cost: listing %d + %d batches of %d repositories * %d\n", listingCost, batches, batchSize, batchCost)
+	return listingCost + batches*batchCost, nil
+}
+
+// estimateSearchCost estimates the credits spent by the searches of UseSearch from their first page
+func (gr *ActivityReport) estimateSearchCost(
+	ctx context.Context,
+	client *graphql.Client,
+	since time.Time,
+	until time.Time) (int, error) {
+
+	cost := 0
+	for _, organization := range gr.organizations() {
+		for _, search := range gr.searches(since, until) {
+			before := gr.usedCredits()
+			respData, err := gr.searchPage(ctx, client, organization, search.qualifiers, since, "")
+			if err != nil {
+				return 0, fmt.Errorf("An error occured during search of %s pull requests of %s: %w", search.name, organization, err)
+			}
+			results := respData.Search.IssueCount
+			if results > maxSearchResults {
+				results = maxSearchResults
+			}
+			pages := (results + gr.PageSize - 1) / gr.PageSize
+			if pages < 1 {
+				pages = 1
+			}
+			gr.logf("Estimated cost of the %s pull requests of %s: %d pages * %d\n", search.name, organization, pages, gr.usedCredits()-before)
+			cost += pages * (gr.usedCredits() - before)
+		}
+	}
+	return cost, nil
+}
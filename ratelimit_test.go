@@ -0,0 +1,235 @@
+package ghreport
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newRateLimitedServer starts a test server listing the repository api with the given rate limit,
+// and counting the repository reports in reports
+func newRateLimitedServer(t *testing.T, remaining int, resetAt time.Time, reports *int32) string {
+	server := newGraphQLServer(t, func(req graphQLRequest, w http.ResponseWriter) {
+		if isListing(req) {
+			fmt.Fprintf(w, `{"data":{"organization":{"repositories":{"nodes":[{"name":"api"}]}},"rateLimit":{"limit":5000,"cost":1,"remaining":%d,"resetAt":%q}}}`,
+				remaining, resetAt.UTC().Format(ISO_FORM))
+			return
+		}
+		atomic.AddInt32(reports, 1)
+		fmt.Fprint(w, repositoryJSON("api", ""))
+	})
+	return server.URL
+}
+
+func TestRunStopsWhenCreditsAreExhausted(t *testing.T) {
+	var reports int32
+	report := newTestReport(newRateLimitedServer(t, 3, time.Now().Add(time.Hour), &reports))
+	report.MinRemainingCredits = 10
+	if err := report.Run(); !errors.Is(err, ErrRateLimitExhausted) {
+		t.Fatalf("Run() = %v, want ErrRateLimitExhausted", err)
+	}
+	if reports != 0 {
+		t.Fatalf("%d repositories reported after the credits were exhausted", reports)
+	}
+}
+
+func TestRunWaitsForRateLimitReset(t *testing.T) {
+	var reports int32
+	resetAt := time.Now().Add(time.Second).Truncate(time.Second).Add(time.Second)
+	report := newTestReport(newRateLimitedServer(t, 3, resetAt, &reports))
+	report.MinRemainingCredits = 10
+	report.WaitForRateLimitReset = true
+	if err := report.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if reports != 1 {
+		t.Fatalf("%d repositories reported, want 1", reports)
+	}
+	if time.Now().Before(resetAt) {
+		t.Fatal("Run() did not wait for the rate limit reset")
+	}
+}
+
+func TestRunIgnoresCreditsAboveMinimum(t *testing.T) {
+	var reports int32
+	report := newTestReport(newRateLimitedServer(t, 100, time.Now().Add(time.Hour), &reports))
+	report.MinRemainingCredits = 10
+	if err := report.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if reports != 1 {
+		t.Fatalf("%d repositories reported, want 1", reports)
+	}
+}
+
+func TestRunRecordsLastRateLimit(t *testing.T) {
+	resetAt := time.Date(2030, 1, 1, 10, 0, 0, 0, time.UTC)
+	server := newGraphQLServer(t, func(req graphQLRequest, w http.ResponseWriter) {
+		if isListing(req) {
+			fmt.Fprintf(w, `{"data":{"organization":{"repositories":{"nodes":[{"name":"api"}]}},"rateLimit":{"limit":5000,"cost":1,"remaining":4000,"resetAt":%q}}}`,
+				resetAt.Format(ISO_FORM))
+			return
+		}
+		fmt.Fprintf(w, `{"data":{"repository":{"name":"api"},"rateLimit":{"limit":5000,"cost":3,"remaining":3997,"resetAt":%q}}}`,
+			resetAt.Format(ISO_FORM))
+	})
+	report := newTestReport(server.URL)
+	if err := report.Run(); err != nil {
+		t.Fatal(err)
+	}
+	want := RateLimit{Limit: 5000, Cost: 3, Remaining: 3997, ResetAt: resetAt}
+	if report.LastRateLimit != want {
+		t.Fatalf("LastRateLimit = %+v, want %+v", report.LastRateLimit, want)
+	}
+}
+
+func TestEstimateCost(t *testing.T) {
+	var reports int32
+	server := newGraphQLServer(t, func(req graphQLRequest, w http.ResponseWriter) {
+		if isListing(req) {
+			fmt.Fprint(w, `{"data":{"organization":{"repositories":{"nodes":[{"name":"api"},{"name":"web"},{"name":"docs"}]}},"rateLimit":{"limit":5000,"cost":2,"remaining":4998}}}`)
+			return
+		}
+		atomic.AddInt32(&reports, 1)
+		fmt.Fprint(w, `{"data":{"repository":{"name":"api"},"rateLimit":{"limit":5000,"cost":5,"remaining":4993}}}`)
+	})
+	report := newTestReport(server.URL)
+	cost, err := report.EstimateCost(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cost != 2+3*5 {
+		t.Fatalf("EstimateCost() = %d, want 2 + 3 * 5", cost)
+	}
+	if reports != 1 {
+		t.Fatalf("%d repositories reported, want a single sample", reports)
+	}
+}
+
+func TestEstimateCostWithBatches(t *testing.T) {
+	var batches int32
+	server := newGraphQLServer(t, func(req graphQLRequest, w http.ResponseWriter) {
+		if isListing(req) {
+			fmt.Fprint(w, `{"data":{"organization":{"repositories":{"nodes":[{"name":"api"},{"name":"web"},{"name":"docs"},{"name":"cli"},{"name":"site"}]}},"rateLimit":{"cost":2}}}`)
+			return
+		}
+		atomic.AddInt32(&batches, 1)
+		if _, ok := req.Variables["repo2"]; ok || req.Variables["repo1"] == nil {
+			t.Errorf("expected a sample batch of 2 repositories, got %v", req.Variables)
+		}
+		fmt.Fprint(w, `{"data":{"repo0":{"name":"api"},"repo1":{"name":"web"},"rateLimit":{"cost":7}}}`)
+	})
+	report := newTestReport(server.URL)
+	report.BatchSize = 2
+	cost, err := report.EstimateCost(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 5 repositories are reported in 3 batches
+	if cost != 2+3*7 {
+		t.Fatalf("EstimateCost() = %d, want 2 + 3 * 7", cost)
+	}
+	if batches != 1 {
+		t.Fatalf("%d batches reported, want a single sample", batches)
+	}
+}
+
+func TestEstimateCostCountsCachedListing(t *testing.T) {
+	var listings int32
+	server := newGraphQLServer(t, func(req graphQLRequest, w http.ResponseWriter) {
+		if isListing(req) {
+			atomic.AddInt32(&listings, 1)
+			fmt.Fprint(w, `{"data":{"organization":{"repositories":{"nodes":[{"name":"api"},{"name":"web"},{"name":"docs"}]}},"rateLimit":{"cost":1}}}`)
+			return
+		}
+		fmt.Fprint(w, `{"data":{"repository":{"name":"api"},"rateLimit":{"cost":5}}}`)
+	})
+	report := newTestReport(server.URL)
+	report.RepositoryCache = NewMemoryCache()
+	for i := 0; i < 2; i++ {
+		cost, err := report.EstimateCost(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cost != 1+3*5 {
+			t.Fatalf("EstimateCost() = %d on estimate %d, want 1 + 3 * 5", cost, i+1)
+		}
+	}
+	if listings != 1 {
+		t.Fatalf("%d listings, want the second one from the cache", listings)
+	}
+}
+
+func TestEstimateCostWithSearch(t *testing.T) {
+	var mu sync.Mutex
+	queries := []string{}
+	server := newGraphQLServer(t, func(req graphQLRequest, w http.ResponseWriter) {
+		query, _ := req.Variables["query"].(string)
+		mu.Lock()
+		queries = append(queries, query)
+		mu.Unlock()
+		count := 0
+		switch {
+		case strings.Contains(query, "is:merged"):
+			count = 250
+		case strings.Contains(query, "is:open"):
+			count = 30
+		}
+		fmt.Fprintf(w, `{"data":{"search":{"nodes":[],"pageInfo":{"hasNextPage":%t},"issueCount":%d},"rateLimit":{"cost":3}}}`, count > 100, count)
+	})
+	report := newTestReport(server.URL)
+	report.UseSearch = true
+	report.PageSize = 100
+	cost, err := report.EstimateCost(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 3 pages of merged, 1 page of open and 1 (empty) page of closed pull requests
+	if cost != (3+1+1)*3 {
+		t.Fatalf("EstimateCost() = %d, want 5 pages * 3", cost)
+	}
+	if len(queries) != 3 {
+		t.Fatalf("queries = %q, want only the first page of each search", queries)
+	}
+}
+
+func TestRunSpacesRequestsByRequestInterval(t *testing.T) {
+	var mu sync.Mutex
+	times := []time.Time{}
+	server := newGraphQLServer(t, func(req graphQLRequest, w http.ResponseWriter) {
+		mu.Lock()
+		times = append(times, time.Now())
+		mu.Unlock()
+		if isListing(req) {
+			fmt.Fprint(w, listingJSON("api", "web", "docs", "cli"))
+			return
+		}
+		repo, _ := req.Variables["repo"].(string)
+		fmt.Fprint(w, repositoryJSON(repo, ""))
+	})
+	interval := 20 * time.Millisecond
+	report := newTestReport(server.URL)
+	report.RequestInterval = interval
+	report.Concurrency = 4
+	if err := report.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	sort.Slice(times, func(i, j int) bool { return times[i].Before(times[j]) })
+	if len(times) != 5 {
+		t.Fatalf("expected 5 requests, got %d", len(times))
+	}
+	for i := 1; i < len(times); i++ {
+		// Allow for the delay between pace returning and the server receiving the request
+		if gap := times[i].Sub(times[i-1]); gap < interval-5*time.Millisecond {
+			t.Errorf("requests %d and %d are %v apart, want at least %v", i-1, i, gap, interval)
+		}
+	}
+}
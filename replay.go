@@ -0,0 +1,151 @@
+package ghreport
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+)
+
+// ErrNoFixture is returned by ReplayTransport when no fixture matches a query
+var ErrNoFixture = errors.New("No fixture recorded for query")
+
+// fixture is the content of a file written by RecordingTransport
+type fixture struct {
+	Query     string                 `json:"query"`
+	Variables map[string]interface{} `json:"variables"`
+	Response  json.RawMessage        `json:"response"`
+}
+
+// fixturePath returns the file of the fixture of a GraphQL request body, named after
+// a hash of its query and variables
+func fixturePath(dir string, body []byte) (string, fixture, error) {
+	var request fixture
+	if err := json.Unmarshal(body, &request); err != nil {
+		return "", request, err
+	}
+	// Marshaling sorts the variables, so the key doesn't depend on their order
+	key, err := json.Marshal(struct {
+		Query     string                 `json:"query"`
+		Variables map[string]interface{} `json:"variables"`
+	}{request.Query, request.Variables})
+	if err != nil {
+		return "", request, err
+	}
+	sum := sha256.Sum256(key)
+	return filepath.Join(dir, hex.EncodeToString(sum[:8])+".json"), request, nil
+}
+
+// readBody returns the body of req, leaving it readable
+func readBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil {
+		return nil, nil
+	}
+	body, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	return body, nil
+}
+
+// RecordingTransport is an http.RoundTripper writing each GraphQL query and its response to
+// a fixture file of Dir, to be served later by ReplayTransport, e.g.
+//
+//	report.Transport = &ghreport.RecordingTransport{Dir: "testdata"}
+//
+// Fixtures are keyed by query and variables, which include the report window: set StartDate
+// and EndDate so that the recorded run can be replayed.
+type RecordingTransport struct {
+	// Dir is the directory where fixtures are written. It must exist.
+	Dir string
+	// Base sends the requests. When nil, http.DefaultTransport is used.
+	Base http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *RecordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := readBody(req)
+	if err != nil {
+		return nil, err
+	}
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	resp, err := base.RoundTrip(req)
+	if err != nil || resp.StatusCode != http.StatusOK {
+		return resp, err
+	}
+	respBody, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(respBody))
+
+	path, record, err := fixturePath(t.Dir, body)
+	if err != nil {
+		return nil, err
+	}
+	record.Response = respBody
+	data, err := json.MarshalIndent(record, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// ReplayTransport is an http.RoundTripper answering GraphQL queries with the fixtures written
+// by RecordingTransport, without network access, e.g.
+//
+//	report.Transport = &ghreport.ReplayTransport{Dir: "testdata"}
+//
+// Queries without fixture fail with ErrNoFixture.
+type ReplayTransport struct {
+	// Dir is the directory where fixtures were written
+	Dir string
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *ReplayTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := readBody(req)
+	if err != nil {
+		return nil, err
+	}
+	path, request, err := fixturePath(t.Dir, body)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("%w %.60q", ErrNoFixture, request.Query)
+	} else if err != nil {
+		return nil, err
+	}
+	var record fixture
+	if err := json.Unmarshal(data, &record); err != nil {
+		return nil, err
+	}
+	return &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": []string{"application/json"}},
+		Body:          io.NopCloser(bytes.NewReader(record.Response)),
+		ContentLength: int64(len(record.Response)),
+		Request:       req,
+	}, nil
+}
@@ -0,0 +1,67 @@
+package ghreport
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestReplayServesRecordedRun(t *testing.T) {
+	server := newRepositoryServer(t, func(repo string) string {
+		return `"mergedPR":{"nodes":[{"number":1,"title":"Fix","mergedAt":"2020-01-05T10:00:00Z"}]},
+			"openPR":{"nodes":[{"number":2,"state":"OPEN","createdAt":"2020-01-02T10:00:00Z","activity":{"totalCount":2}}]}`
+	}, "api", "web")
+	dir := t.TempDir()
+	newWindowReport := func() *ActivityReport {
+		report := newTestReport(server.URL)
+		report.StartDate = time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+		report.EndDate = time.Date(2020, 1, 8, 0, 0, 0, 0, time.UTC)
+		return report
+	}
+
+	recorded := newWindowReport()
+	recorded.Transport = &RecordingTransport{Dir: dir}
+	if err := recorded.Run(); err != nil {
+		t.Fatal(err)
+	}
+	server.Close()
+
+	replayed := newWindowReport()
+	replayed.Transport = &ReplayTransport{Dir: dir}
+	if err := replayed.Run(); err != nil {
+		t.Fatal(err)
+	}
+	// The report date is the time of the run
+	replayed.Result.ReportDate = recorded.Result.ReportDate
+	if !reflect.DeepEqual(recorded.Result, replayed.Result) {
+		t.Errorf("replayed result %+v, want %+v", replayed.Result, recorded.Result)
+	}
+	if len(replayed.Result.MergedPRs) != 2 {
+		t.Errorf("MergedPRs = %+v, want one per repository", replayed.Result.MergedPRs)
+	}
+}
+
+func TestReplayFailsWithoutFixture(t *testing.T) {
+	report := newTestReport("http://127.0.0.1:0")
+	report.Transport = &ReplayTransport{Dir: t.TempDir()}
+	report.MaxRetries = 0
+	if err := report.Run(); !errors.Is(err, ErrNoFixture) {
+		t.Errorf("expected ErrNoFixture, got %v", err)
+	}
+}
+
+func TestFixturePathIgnoresVariableOrder(t *testing.T) {
+	first, _, err := fixturePath("fixtures", []byte(`{"query":"q","variables":{"a":1,"b":2}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, _, err := fixturePath("fixtures", []byte(`{"variables":{"b":2,"a":1},"query":"q"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, _, _ := fixturePath("fixtures", []byte(`{"query":"q","variables":{"a":3,"b":2}}`))
+	if first != second || first == other {
+		t.Errorf("fixture paths %s, %s and %s: want the first two equal and the last different", first, second, other)
+	}
+}
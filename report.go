@@ -1,11 +1,18 @@
 package ghreport
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
-  "strings"
-	//"sort"
-	"context"
+	"net/http"
+	"net/url"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
 	"time"
 
 	"golang.org/x/oauth2"
@@ -15,51 +22,478 @@ import (
 
 const ISO_FORM = "2006-01-02T15:04:05Z"
 
+// DefaultBaseURL is the GraphQL endpoint of the public GitHub API
+const DefaultBaseURL = "https://api.github.com/graphql"
+
+// DefaultPageSize is the number of nodes fetched per GraphQL connection by default
+const DefaultPageSize = 50
+
+// DefaultConcurrency is the number of repositories queried in parallel by default
+const DefaultConcurrency = 4
+
+// Repository affiliations accepted in ActivityReport.Affiliations
+const (
+	AffiliationOwner              = "OWNER"
+	AffiliationCollaborator       = "COLLABORATOR"
+	AffiliationOrganizationMember = "ORGANIZATION_MEMBER"
+)
+
+// Account types accepted in ActivityReport.AccountType
+const (
+	AccountOrganization = "ORGANIZATION"
+	AccountUser         = "USER"
+)
+
+// Visibilities of repositories, used to filter the repositories reported
+const (
+	VisibilityAll      = "ALL"
+	VisibilityPublic   = "PUBLIC"
+	VisibilityPrivate  = "PRIVATE"
+	VisibilityInternal = "INTERNAL"
+)
+
+// DefaultActivityThreshold is the number of events from which an open pull request is active by default
+const DefaultActivityThreshold = 1
+
+// PROrder defines the order in which pull requests are queried
+type PROrder struct {
+	// Field is CREATED_AT or UPDATED_AT
+	Field string `json:"field"`
+	// Direction is ASC or DESC
+	Direction string `json:"direction"`
+}
+
+// Pull request states, as returned by GitHub
+const (
+	StateOpen   = "OPEN"
+	StateClosed = "CLOSED"
+	StateMerged = "MERGED"
+)
+
+// PRBucket identifies how the pull requests of a state are classified in Result
+type PRBucket string
+
+const (
+	// BucketMerged lists the pull requests merged (or closed) during the window in MergedPRs
+	BucketMerged PRBucket = "merged"
+	// BucketOpen lists the pull requests in OpenPRsWithActivity or OpenPRsWithoutActivity
+	BucketOpen PRBucket = "open"
+	// BucketClosed lists the pull requests closed during the window in ClosedPRs
+	BucketClosed PRBucket = "closed"
+	// BucketIgnore leaves the pull requests out of the report, and they are not queried
+	BucketIgnore PRBucket = "ignore"
+)
+
+// DefaultStateMapping classifies each state in its own bucket
+var DefaultStateMapping = map[string]PRBucket{
+	StateMerged: BucketMerged,
+	StateOpen:   BucketOpen,
+	StateClosed: BucketClosed,
+}
+
+// DefaultMergedOrder queries the most recently updated merged pull requests first.
+// A pull request is updated when merged, so the ones merged during the window come first.
+var DefaultMergedOrder = PROrder{Field: "UPDATED_AT", Direction: "DESC"}
+
+// MaxBatchSize is the maximum number of repositories queried at once with BatchSize,
+// which keeps a batch under the GitHub limits on query cost and returned nodes
+const MaxBatchSize = 10
+
+// MaxPageSize is the maximum number of nodes GitHub accepts per GraphQL connection
+const MaxPageSize = 100
+
 // PageInfoStruct defines the structure sent by GitHub GraphQL API for Pagination
 type PageInfoStruct struct {
-	HasNextPage     bool
-	StartCursor     string
-	EndCursor       string
-	HasPreviousPage bool
+	HasNextPage     bool   `json:"hasNextPage"`
+	StartCursor     string `json:"startCursor"`
+	EndCursor       string `json:"endCursor"`
+	HasPreviousPage bool   `json:"hasPreviousPage"`
 }
 
 // RateLimitStruct defines the structure sent by GitHub GraphQL API for Rate limiting
 type RateLimitStruct struct {
-	Limit     int
-	Cost      int
-	Remaining int
-	ResetAt   string
+	Limit     int    `json:"limit"`
+	Cost      int    `json:"cost"`
+	Remaining int    `json:"remaining"`
+	ResetAt   string `json:"resetAt"`
 }
 
 // UserStruct defines the structure sent by GitHub GraphQL API for Users
 type UserStruct struct {
-	Login string
+	Login string `json:"login"`
+}
+
+// RepositoryStruct defines the structure sent by GitHub GraphQL API for Repositories
+type RepositoryStruct struct {
+	Name     string
+	Owner    UserStruct
+	PushedAt string
+	// Visibility is PUBLIC, PRIVATE or INTERNAL
+	Visibility string
 }
 
 // PRStruct defines the structure sent by GitHub GraphQL API for PullRequests
 type PRStruct struct {
-	Number       int
-	Title        string
-	Repository   string
-	CreatedAt    string
-	MergedAt     string
-	State        string
+	Number       int        `json:"number"`
+	Title        string     `json:"title"`
+	Org          string     `json:"org"`
+	Repository   string     `json:"repository"`
+	CreatedAt    string     `json:"createdAt"`
+	UpdatedAt    string     `json:"updatedAt"`
+	MergedAt     string     `json:"mergedAt"`
+	ClosedAt     string     `json:"closedAt"`
+	State        string     `json:"state"`
+	IsDraft      bool       `json:"isDraft"`
+	Author       UserStruct `json:"author"`
+	Labels       LabelList  `json:"labels"`
 	Participants struct {
-		Nodes      []UserStruct
-		PageInfo   PageInfoStruct
-		TotalCount int
-	}
+		Nodes      []UserStruct   `json:"nodes"`
+		PageInfo   PageInfoStruct `json:"pageInfo"`
+		TotalCount int            `json:"totalCount"`
+	} `json:"participants"`
 	Timeline struct {
+		TotalCount int `json:"totalCount"`
+	} `json:"timeline"`
+	// Activity counts the commits, comments and reviews since the beginning of the report window
+	Activity struct {
+		TotalCount int `json:"totalCount"`
+	} `json:"activity"`
+	Reviews struct {
+		TotalCount int `json:"totalCount"`
+	} `json:"reviews"`
+	Additions int `json:"additions"`
+	Deletions int `json:"deletions"`
+	// ReviewDecision is APPROVED, CHANGES_REQUESTED, REVIEW_REQUIRED or empty when no review is required
+	ReviewDecision string `json:"reviewDecision"`
+	// RequestedReviewers lists the reviewers whose review is still pending.
+	// Users are listed by login and teams as "team:<slug>".
+	RequestedReviewers ReviewerList `json:"requestedReviewers"`
+	// FirstReviewAt is the submission date of the first review, empty when the pull request has no review
+	FirstReviewAt ReviewDate `json:"firstReviewAt"`
+	// Mergeable is MERGEABLE, CONFLICTING or UNKNOWN for open pull requests.
+	// UNKNOWN is common right after a push, while GitHub computes mergeability.
+	Mergeable string `json:"mergeable"`
+	// MergeCommitOid is the oid of the commit created by the merge, empty for unmerged pull requests.
+	// It can be looked up in the branch histories summarized in Result.Commits.
+	MergeCommitOid CommitOid `json:"mergeCommitOid"`
+	// ChangedFiles lists the paths of the files changed by the pull request. It is capped to the
+	// first PageSize files: ChangedFileCount gives the actual number of files changed.
+	ChangedFiles     FileList `json:"changedFiles"`
+	ChangedFileCount int      `json:"changedFileCount"`
+	// LastCommitAt is the date of the last commit of an open pull request
+	LastCommitAt CommitDate `json:"lastCommitAt"`
+	// RecentCommentCount counts the general comments posted on an open pull request since the
+	// beginning of the report window, and RecentReviewCommentCount the code review comments
+	// posted in its reviews since then. Merged and closed pull requests only have them with UseSearch.
+	RecentCommentCount       CommentCount `json:"recentCommentCount"`
+	RecentReviewCommentCount CommentCount `json:"recentReviewCommentCount"`
+}
+
+// TotalChurn returns the number of lines added and deleted by the pull request
+func (pr PRStruct) TotalChurn() int {
+	return pr.Additions + pr.Deletions
+}
+
+// ReviewCount returns the number of reviews submitted on the pull request
+func (pr PRStruct) ReviewCount() int {
+	return pr.Reviews.TotalCount
+}
+
+// TimeToFirstReview returns the time between the creation and the first review of the pull request.
+// It returns 0 when the pull request has no review (FirstReviewAt is empty) or a date is unparseable.
+func (pr PRStruct) TimeToFirstReview() time.Duration {
+	created, err1 := time.Parse(ISO_FORM, pr.CreatedAt)
+	reviewed, err2 := time.Parse(ISO_FORM, string(pr.FirstReviewAt))
+	if err1 != nil || err2 != nil {
+		return 0
+	}
+	return reviewed.Sub(created)
+}
+
+// Age returns the time elapsed between the creation of the pull request and now.
+// ok is false, and the age 0, when CreatedAt is unparseable.
+func (pr PRStruct) Age(now time.Time) (age time.Duration, ok bool) {
+	created, err := time.Parse(ISO_FORM, pr.CreatedAt)
+	if err != nil {
+		return 0, false
+	}
+	return now.Sub(created), true
+}
+
+// IssueStruct defines the structure sent by GitHub GraphQL API for Issues
+type IssueStruct struct {
+	Number     int       `json:"number"`
+	Title      string    `json:"title"`
+	Org        string    `json:"org"`
+	Repository string    `json:"repository"`
+	State      string    `json:"state"`
+	CreatedAt  string    `json:"createdAt"`
+	UpdatedAt  string    `json:"updatedAt"`
+	ClosedAt   string    `json:"closedAt"`
+	Labels     LabelList `json:"labels"`
+}
+
+// CommitAuthorStruct defines the structure sent by GitHub GraphQL API for commit authors
+type CommitAuthorStruct struct {
+	Name string
+	User UserStruct
+}
+
+// CommitStruct defines the structure sent by GitHub GraphQL API for Commits
+type CommitStruct struct {
+	Oid           string
+	CommittedDate string
+	Author        CommitAuthorStruct
+	Message       string
+}
+
+// RefStruct defines the structure sent by GitHub GraphQL API for branches and their commit history
+type RefStruct struct {
+	Name   string
+	Target struct {
+		History struct {
+			Nodes      []CommitStruct
+			PageInfo   PageInfoStruct
+			TotalCount int
+		}
+	}
+}
+
+// unmarshalPlainOrGraphQL decodes the JSON of the custom types below, written either by GitHub
+// as a GraphQL object (a connection, a commit...) or by the JSON export as a plain value
+// (a string, a number, an array or null). A plain value is decoded into plain, an object into
+// graphQL; isGraphQL reports which one was decoded.
+func unmarshalPlainOrGraphQL(data []byte, plain interface{}, graphQL interface{}) (isGraphQL bool, err error) {
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) > 0 && trimmed[0] != '{' {
+		return false, json.Unmarshal(trimmed, plain)
+	}
+	return true, json.Unmarshal(trimmed, graphQL)
+}
+
+// LabelList holds the names of the labels of a pull request.
+// It decodes both the GraphQL labels connection and a plain JSON array of names.
+type LabelList []string
+
+// UnmarshalJSON implements json.Unmarshaler
+func (l *LabelList) UnmarshalJSON(data []byte) error {
+	var connection struct {
+		Nodes []struct {
+			Name string
+		}
+	}
+	if isGraphQL, err := unmarshalPlainOrGraphQL(data, (*[]string)(l), &connection); err != nil || !isGraphQL {
+		return err
+	}
+	names := []string{}
+	for _, node := range connection.Nodes {
+		names = append(names, node.Name)
+	}
+	*l = names
+	return nil
+}
+
+// FileList holds the paths of the files changed by a pull request.
+// It decodes both the GraphQL files connection and a plain JSON array of paths.
+type FileList []string
+
+// UnmarshalJSON implements json.Unmarshaler
+func (l *FileList) UnmarshalJSON(data []byte) error {
+	var connection struct {
+		Nodes []struct {
+			Path string
+		}
+	}
+	if isGraphQL, err := unmarshalPlainOrGraphQL(data, (*[]string)(l), &connection); err != nil || !isGraphQL {
+		return err
+	}
+	paths := []string{}
+	for _, node := range connection.Nodes {
+		paths = append(paths, node.Path)
+	}
+	*l = paths
+	return nil
+}
+
+// TeamReviewerPrefix prefixes the slug of teams in RequestedReviewers
+const TeamReviewerPrefix = "team:"
+
+// ReviewerList holds the pending reviewers of a pull request.
+// It decodes both the GraphQL reviewRequests connection and a plain JSON array of names.
+type ReviewerList []string
+
+// UnmarshalJSON implements json.Unmarshaler
+func (l *ReviewerList) UnmarshalJSON(data []byte) error {
+	var connection struct {
+		Nodes []struct {
+			RequestedReviewer struct {
+				Login string
+				Slug  string
+			}
+		}
+	}
+	if isGraphQL, err := unmarshalPlainOrGraphQL(data, (*[]string)(l), &connection); err != nil || !isGraphQL {
+		return err
+	}
+	names := []string{}
+	for _, node := range connection.Nodes {
+		reviewer := node.RequestedReviewer
+		if reviewer.Login != "" {
+			names = append(names, reviewer.Login)
+		} else if reviewer.Slug != "" {
+			names = append(names, TeamReviewerPrefix+reviewer.Slug)
+		}
+		// Other reviewers (e.g. mannequins) have neither and are skipped
+	}
+	*l = names
+	return nil
+}
+
+// ReviewDate holds the submission date of a review.
+// It decodes both a GraphQL reviews connection, keeping its first node, and a plain JSON string.
+type ReviewDate string
+
+// UnmarshalJSON implements json.Unmarshaler
+func (d *ReviewDate) UnmarshalJSON(data []byte) error {
+	var date *string
+	var connection struct {
+		Nodes []struct {
+			SubmittedAt string
+		}
+	}
+	isGraphQL, err := unmarshalPlainOrGraphQL(data, &date, &connection)
+	if err != nil {
+		return err
+	}
+	if isGraphQL && len(connection.Nodes) > 0 {
+		date = &connection.Nodes[0].SubmittedAt
+	}
+	if date != nil {
+		*d = ReviewDate(*date)
+	}
+	return nil
+}
+
+// CommitDate holds the date of a commit.
+// It decodes both a GraphQL commits connection, keeping the committed date of its first node,
+// and a plain JSON string.
+type CommitDate string
+
+// UnmarshalJSON implements json.Unmarshaler
+func (d *CommitDate) UnmarshalJSON(data []byte) error {
+	var date *string
+	var connection struct {
+		Nodes []struct {
+			Commit struct {
+				CommittedDate string
+			}
+		}
+	}
+	isGraphQL, err := unmarshalPlainOrGraphQL(data, &date, &connection)
+	if err != nil {
+		return err
+	}
+	if isGraphQL && len(connection.Nodes) > 0 {
+		date = &connection.Nodes[0].Commit.CommittedDate
+	}
+	if date != nil {
+		*d = CommitDate(*date)
+	}
+	return nil
+}
+
+// CommentCount holds a number of comments.
+// It decodes a plain JSON number, a GraphQL connection (its totalCount) and a GraphQL list of
+// reviews, summing the totalCount of their comments.
+type CommentCount int
+
+// UnmarshalJSON implements json.Unmarshaler
+func (c *CommentCount) UnmarshalJSON(data []byte) error {
+	var count *int
+	var connection struct {
 		TotalCount int
+		Nodes      []struct {
+			Comments struct {
+				TotalCount int
+			}
+		}
+	}
+	isGraphQL, err := unmarshalPlainOrGraphQL(data, &count, &connection)
+	if err != nil {
+		return err
+	}
+	if isGraphQL {
+		total := connection.TotalCount
+		if connection.Nodes != nil {
+			total = 0
+			for _, review := range connection.Nodes {
+				total += review.Comments.TotalCount
+			}
+		}
+		count = &total
+	}
+	if count != nil {
+		*c = CommentCount(*count)
+	}
+	return nil
+}
+
+// CommitOid holds the oid of a commit.
+// It decodes both a GraphQL commit object and a plain JSON string.
+type CommitOid string
+
+// UnmarshalJSON implements json.Unmarshaler
+func (o *CommitOid) UnmarshalJSON(data []byte) error {
+	var oid *string
+	var commit struct {
+		Oid string
+	}
+	isGraphQL, err := unmarshalPlainOrGraphQL(data, &oid, &commit)
+	if err != nil {
+		return err
+	}
+	if isGraphQL {
+		oid = &commit.Oid
+	}
+	if oid != nil {
+		*o = CommitOid(*oid)
+	}
+	return nil
+}
+
+// lessTimestamp orders GitHub ISO timestamps chronologically.
+// Empty or unparseable timestamps sort last, so the ordering stays consistent.
+func lessTimestamp(a string, b string) bool {
+	ta, errA := time.Parse(ISO_FORM, a)
+	tb, errB := time.Parse(ISO_FORM, b)
+	return lessTime(ta, errA == nil, tb, errB == nil)
+}
+
+// lessTime orders valid times chronologically before invalid ones
+func lessTime(ta time.Time, validA bool, tb time.Time, validB bool) bool {
+	if !validA {
+		return false
+	}
+	if !validB {
+		return true
 	}
+	return ta.Before(tb)
 }
 
-// ByActivity allows to sort PRStruct by number of events
+// ByActivity allows to sort PRStruct by activity (commits, comments and reviews in the window),
+// the same count that decides whether an open pull request is active
 type ByActivity []PRStruct
 
 func (a ByActivity) Len() int           { return len(a) }
 func (a ByActivity) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
-func (a ByActivity) Less(i, j int) bool { return a[i].Timeline.TotalCount > a[j].Timeline.TotalCount }
+func (a ByActivity) Less(i, j int) bool { return a[i].Activity.TotalCount > a[j].Activity.TotalCount }
+
+// ByChurn allows to sort PRStruct by number of lines changed, biggest first
+type ByChurn []PRStruct
+
+func (a ByChurn) Len() int           { return len(a) }
+func (a ByChurn) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a ByChurn) Less(i, j int) bool { return a[i].TotalChurn() > a[j].TotalChurn() }
 
 // ByAge allows to sort PRStruct by creation date
 type ByAge []PRStruct
@@ -67,9 +501,7 @@ type ByAge []PRStruct
 func (a ByAge) Len() int      { return len(a) }
 func (a ByAge) Swap(i, j int) { a[i], a[j] = a[j], a[i] }
 func (a ByAge) Less(i, j int) bool {
-	ti, _ := time.Parse(ISO_FORM, a[i].CreatedAt)
-	tj, _ := time.Parse(ISO_FORM, a[j].CreatedAt)
-	return tj.After(ti)
+	return lessTimestamp(a[i].CreatedAt, a[j].CreatedAt)
 }
 
 // ByMerge allows to sort PRStruct by merged date
@@ -78,9 +510,7 @@ type ByMerge []PRStruct
 func (a ByMerge) Len() int      { return len(a) }
 func (a ByMerge) Swap(i, j int) { a[i], a[j] = a[j], a[i] }
 func (a ByMerge) Less(i, j int) bool {
-  ti, _ := time.Parse(ISO_FORM, a[i].MergedAt)
-  tj, _ := time.Parse(ISO_FORM, a[j].MergedAt)
-  return tj.After(ti)
+	return lessTimestamp(a[i].MergedAt, a[j].MergedAt)
 }
 
 // ByTitle allows to sort PRStruct by title
@@ -93,53 +523,172 @@ func (a ByTitle) Less(i, j int) bool {
 }
 
 type repositoriesResponseStruct struct {
-	Organization struct {
-		Repositories struct {
-			Nodes []struct {
-				Name  string
-				Owner UserStruct
-			}
-			PageInfo   PageInfoStruct
-			TotalCount int
-		}
+	Organization *ownerRepositoriesStruct
+	User         *ownerRepositoriesStruct
+	RateLimit    RateLimitStruct
+}
+
+// ownerRepositoriesStruct defines the structure sent by GitHub GraphQL API for the repositories
+// of an organization or a user
+type ownerRepositoriesStruct struct {
+	Repositories struct {
+		Nodes      []RepositoryStruct
+		PageInfo   PageInfoStruct
+		TotalCount int
 	}
-	RateLimit RateLimitStruct
+}
+
+// owner returns the organization or the user listed, nil when GitHub could not resolve it
+func (r repositoriesResponseStruct) owner() *ownerRepositoriesStruct {
+	if r.Organization != nil {
+		return r.Organization
+	}
+	return r.User
 }
 
 type reportResponseStruct struct {
-	Repository struct {
-		Name     string
-		MergedPR struct {
-			Nodes      []PRStruct
-			PageInfo   PageInfoStruct
-			TotalCount int
-		}
-		OpenPR struct {
-			Nodes      []PRStruct
-			PageInfo   PageInfoStruct
-			TotalCount int
-		}
-		Refs struct {
-			Nodes []struct {
-				Name   string
-				Target struct {
-					History struct {
-						Nodes []struct {
-							Oid           string
-							CommittedDate string
-							Author        UserStruct
-							Message       string
-						}
-						PageInfo   PageInfoStruct
-						TotalCount int
-					}
-				}
+	Repository repositoryReportStruct
+	RateLimit  RateLimitStruct
+}
+
+// repositoryReportStruct defines the structure sent by GitHub GraphQL API for a reported repository
+type repositoryReportStruct struct {
+	Name     string
+	MergedPR struct {
+		Nodes      []PRStruct
+		PageInfo   PageInfoStruct
+		TotalCount int
+	}
+	OpenPR struct {
+		Nodes      []PRStruct
+		PageInfo   PageInfoStruct
+		TotalCount int
+	}
+	ClosedPR struct {
+		Nodes      []PRStruct
+		PageInfo   PageInfoStruct
+		TotalCount int
+	}
+	Refs struct {
+		Nodes      []RefStruct
+		PageInfo   PageInfoStruct
+		TotalCount int
+	}
+	Issues struct {
+		Nodes      []IssueStruct
+		PageInfo   PageInfoStruct
+		TotalCount int
+	}
+	DefaultBranchRef *RefStruct
+}
+
+// Result holds the pull requests extracted by a report
+type Result struct {
+	ReportDate             time.Time  `json:"reportDate"`
+	MergedPRs              []PRStruct `json:"mergedPRs"`
+	OpenPRsWithActivity    []PRStruct `json:"openPRsWithActivity"`
+	OpenPRsWithoutActivity []PRStruct `json:"openPRsWithoutActivity"`
+	ClosedPRs              []PRStruct `json:"closedPRs"`
+
+	// DraftPRs holds the open draft pull requests when SeparateDrafts is set
+	DraftPRs []PRStruct `json:"draftPRs"`
+
+	// StalePRs holds the open pull requests idle for longer than StaleAfter.
+	// They are also listed in the open with/without activity or draft lists.
+	StalePRs []PRStruct `json:"stalePRs"`
+
+	// ConflictingPRs holds the open pull requests with merge conflicts (Mergeable is CONFLICTING).
+	// They are also listed in the open with/without activity or draft lists.
+	ConflictingPRs []PRStruct `json:"conflictingPRs"`
+
+	// Issues holds the issues created, updated or closed during the report window
+	Issues []IssueStruct `json:"issues"`
+
+	// Commits summarizes the commits of the report window, by repository full name ("org/repo")
+	Commits map[string]CommitSummary `json:"commits"`
+
+	// Errors lists the repositories skipped because of a failure when SkipFailedRepos is set
+	Errors []error `json:"-"`
+
+	// TotalRepositories is the number of repositories of the organizations and ScannedRepositories
+	// the number of them actually reported, see ActivityReport.TotalRepositories
+	TotalRepositories   int `json:"totalRepositories"`
+	ScannedRepositories int `json:"scannedRepositories"`
+
+	// CachedListings is the number of organizations whose repository listing came from the
+	// RepositoryCache, and SavedQueries the number of listing queries, each one costing at least
+	// one credit, that these cache hits saved
+	CachedListings int `json:"cachedListings"`
+	SavedQueries   int `json:"savedQueries"`
+}
+
+// CommitSummary summarizes the commits pushed to a repository during the report window
+type CommitSummary struct {
+	Commits      int       `json:"commits"`
+	Authors      []string  `json:"authors"`
+	LastCommitAt time.Time `json:"lastCommitAt"`
+	// Types counts the commits by conventional-commit type (feat, fix...), "unknown" for the others
+	Types map[string]int `json:"types"`
+}
+
+// ErrOrganizationNotFound is returned when an organization of the report (or a user account,
+// see AccountType) doesn't exist
+var ErrOrganizationNotFound = errors.New("Organization not found")
+
+// organizationNotFound returns the error of the repository listing of organization:
+// ErrOrganizationNotFound when GitHub could not resolve the organization, err otherwise
+func organizationNotFound(organization string, err error) error {
+	var graphQLErr *GraphQLError
+	if errors.As(err, &graphQLErr) {
+		for _, errorType := range graphQLErr.Types {
+			if errorType == "NOT_FOUND" {
+				return fmt.Errorf("%w: %s", ErrOrganizationNotFound, organization)
 			}
-			PageInfo   PageInfoStruct
-			TotalCount int
 		}
 	}
-	RateLimit RateLimitStruct
+	if err != nil && (strings.Contains(err.Error(), "Could not resolve to an Organization") ||
+		strings.Contains(err.Error(), "Could not resolve to a User")) {
+		return fmt.Errorf("%w: %s", ErrOrganizationNotFound, organization)
+	}
+	return err
+}
+
+// ErrIncomplete is matched by the errors returned when a report stops before the end of the scan
+var ErrIncomplete = errors.New("Report incomplete")
+
+// IncompleteError is returned along with a partial result when a report stops early
+type IncompleteError struct {
+	Err error
+}
+
+func (e *IncompleteError) Error() string {
+	return fmt.Sprintf("%v: %v", ErrIncomplete, e.Err)
+}
+
+// Unwrap returns the cause of the early stop
+func (e *IncompleteError) Unwrap() error {
+	return e.Err
+}
+
+// Is makes errors.Is(err, ErrIncomplete) match
+func (e *IncompleteError) Is(target error) bool {
+	return target == ErrIncomplete
+}
+
+// RepositoryError records the failure of the report of one repository
+type RepositoryError struct {
+	Organization string
+	Repository   string
+	Err          error
+}
+
+func (e *RepositoryError) Error() string {
+	return fmt.Sprintf("An error occured during report for %s/%s: %v", e.Organization, e.Repository, e.Err)
+}
+
+// Unwrap returns the underlying error
+func (e *RepositoryError) Unwrap() error {
+	return e.Err
 }
 
 // ActivityReport object
@@ -147,48 +696,500 @@ type ActivityReport struct {
 	Organization string
 	Duration     int
 	ReportDate   time.Time
-	Result       struct {
-		MergedPRs              []PRStruct
-		OpenPRsWithActivity    []PRStruct
-		OpenPRsWithoutActivity []PRStruct
-	}
+
+	// Location is the time zone of ReportDate, of the report window and of the dates displayed
+	// in rendered reports. Defaults to UTC.
+	Location *time.Location
+
+	// Organizations lists the organizations to aggregate in the report.
+	// When empty, only Organization is reported.
+	Organizations []string
+
+	// BaseURL is the GraphQL endpoint to query.
+	// It defaults to DefaultBaseURL and can be changed for GitHub Enterprise, e.g.:
+	//  report.BaseURL = "https://github.mycorp.com/api/graphql"
+	BaseURL string
+
+	// FullScan selects how repositories are listed.
+	// When true (the default set by NewActivityReport), every repository of the organization is reported.
+	// When false, only a small subset of repositories is reported (the first two pages of 10
+	// repositories), which is mainly useful for testing.
+	FullScan bool
+
+	// UseSearch finds the pull requests with the GitHub search across all repositories of each
+	// organization instead of querying the repositories one by one. Merged and closed pull requests
+	// are bounded by the window rather than truncated to the last PageSize of each repository.
+	// IncludeRepos, ExcludeRepos, Visibility and SkipInactiveRepos apply, but issues and commits
+	// are not collected and the repository counts stay zero. The GitHub search returns at most 1000 results.
+	UseSearch bool
+
+	// AccountType tells whether Organization (and Organizations) are organizations (AccountOrganization)
+	// or user accounts (AccountUser) whose repositories are reported. Defaults to AccountOrganization.
+	AccountType string
+
+	// Affiliations selects the repositories listed according to their affiliation with the organization:
+	// AffiliationOwner, AffiliationCollaborator and/or AffiliationOrganizationMember. Defaults to owned repositories.
+	Affiliations []string
+
+	// Visibility restricts the report to the repositories with this visibility: VisibilityPublic,
+	// VisibilityPrivate or VisibilityInternal. VisibilityAll, the default, reports all of them.
+	Visibility string
+
+	// PageSize is the number of nodes fetched per GraphQL connection (repositories, pull requests,
+	// participants, commits...). It must be between 1 and MaxPageSize and defaults to DefaultPageSize.
+	PageSize int
+
+	// MaxRetries is the number of times a query failing with a transient error (server error,
+	// secondary rate limit or network timeout) is retried. RetryDelay is the delay before the first
+	// retry; it doubles after each attempt. A secondary rate limit (403 or 429 with a Retry-After
+	// header) waits for the delay given by GitHub instead. Other client errors (4xx) are never retried.
+	MaxRetries int
+	RetryDelay time.Duration
+
+	// RequestTimeout bounds the duration of each query sent to GitHub, independently of the context
+	// given to RunContext. A query exceeding it fails with ErrRequestTimeout. Zero means no timeout.
+	RequestTimeout time.Duration
+
+	// RetryTimeouts retries the queries exceeding RequestTimeout like the other transient errors.
+	// By default they fail fast, so that a stuck repository is skipped with SkipFailedRepos.
+	RetryTimeouts bool
+
+	// MinRemainingCredits stops the scan before a repository query when the GitHub credits left
+	// are below this threshold: Run returns ErrRateLimitExhausted, or waits until the rate limit
+	// is reset when WaitForRateLimitReset is true. Zero disables the check.
+	MinRemainingCredits   int
+	WaitForRateLimitReset bool
+
+	// RequestInterval, when set, is the minimum delay between two queries sent to GitHub, whatever
+	// the Concurrency, to avoid triggering the GitHub secondary rate limits.
+	RequestInterval time.Duration
+
+	// Concurrency is the number of repositories queried in parallel. It defaults to DefaultConcurrency.
+	// The Log callback is never called concurrently.
+	Concurrency int
+
+	// BatchSize is the number of repositories queried with a single GraphQL query, using aliases.
+	// Batches save round-trips on large organizations; the failure of a batch fails each of its
+	// repositories. It must not exceed MaxBatchSize. Defaults to 1, one query per repository.
+	BatchSize int
+
+	// IncludeRepos and ExcludeRepos filter the repositories to report using glob patterns
+	// such as "api-*". When IncludeRepos is empty, every repository is included.
+	// ExcludeRepos takes precedence over IncludeRepos.
+	IncludeRepos []string
+	ExcludeRepos []string
+
+	// StartDate and EndDate define an explicit report window, e.g. a past sprint.
+	// When StartDate is set, it overrides Duration; EndDate defaults to the report date.
+	StartDate time.Time
+	EndDate   time.Time
+
+	// Authors restricts the report to pull requests opened by one of these logins (case insensitive).
+	// When empty, pull requests from every author are reported.
+	Authors []string
+
+	// ExcludeBots removes bot accounts from the participants of the reported pull requests,
+	// and clears their author when it is a bot, so they count neither in DistinctParticipants
+	// nor in AuthorStats. Bots are the logins ending in "[bot]" (e.g. "dependabot[bot]") or
+	// matching one of the glob patterns of BotPatterns (e.g. "*-ci").
+	ExcludeBots bool
+	BotPatterns []string
+
+	// LabelFilter restricts the report to pull requests carrying at least one of these labels.
+	// When empty, pull requests are reported whatever their labels.
+	LabelFilter []string
+
+	// PathFilter restricts the report to pull requests changing at least one file matching one of
+	// these glob patterns, e.g. "docs/*.md". A pattern also matches the files below the directories
+	// it matches, e.g. "services/api" matches "services/api/main.go". Only the first PageSize files
+	// of each pull request are checked (see PRStruct.ChangedFiles).
+	PathFilter []string
+
+	// SkipFailedRepos makes the report log and skip the repositories whose query fails instead of
+	// aborting. The failures are collected as *RepositoryError in Result.Errors.
+	SkipFailedRepos bool
+
+	// SkipInactiveRepos skips the repositories with no push since the beginning of the report window,
+	// which saves the credits of their report query.
+	SkipInactiveRepos bool
+
+	// AllowPartialData uses the data GitHub returns along with GraphQL errors (e.g. a field
+	// denied by permissions) and logs the errors as warnings. By default such responses
+	// fail with a *GraphQLError.
+	AllowPartialData bool
+
+	// SeparateDrafts routes open draft pull requests to Result.DraftPRs instead of
+	// the open with/without activity lists, so they don't count in the review queue.
+	SeparateDrafts bool
+
+	// ActivityThreshold is the number of commits, comments or reviews during the window from which
+	// an open pull request is considered active. It defaults to DefaultActivityThreshold.
+	ActivityThreshold int
+
+	// CountRecentCommits makes an open pull request whose last commit is dated during the window
+	// active, whatever ActivityThreshold, e.g. after a force-push that left no timeline event.
+	// It is set by NewActivityReport.
+	CountRecentCommits bool
+
+	// RepositoryQuery and RepositoriesQuery replace the GraphQL queries reporting a repository and
+	// listing the repositories of an organization, e.g. to select additional fields. They default to
+	// DefaultRepositoryQuery and DefaultRepositoriesQuery, which are the best starting points: a custom
+	// query must declare the same variables and keep the fields decoded by the report. Extra fields
+	// are ignored by the report structs, but can be read from OnResponse. RepositoryQuery is not used
+	// for batches (BatchSize above 1) nor for the following pages of a repository.
+	RepositoryQuery   string
+	RepositoriesQuery string
+
+	// MergedOrder is the order of the merged pull requests query. It defaults to DefaultMergedOrder,
+	// with which pages are fetched until the whole window is covered. Only DESC orders are accepted,
+	// as ASC ones would start with the oldest pull requests. With CREATED_AT, only the first PageSize
+	// merged pull requests of each repository are fetched, and a warning is logged when there are more.
+	MergedOrder PROrder
+
+	// StateMapping maps each pull request state (StateOpen, StateClosed, StateMerged) to the bucket
+	// of Result it is classified into, e.g. to count closed pull requests as merged or to ignore them.
+	// States missing from the mapping are ignored, and StateOpen can only be mapped to BucketOpen
+	// or BucketIgnore. Defaults to DefaultStateMapping.
+	StateMapping map[string]PRBucket
+
+	// BaseBranch, when set, restricts the report to the pull requests targeting this branch,
+	// e.g. "release/2.x".
+	BaseBranch string
+
+	// StaleAfter, when set, collects in Result.StalePRs the open pull requests without activity
+	// during the window whose last update is older than StaleAfter at the end of the window.
+	StaleAfter time.Duration
+
+	// DefaultBranchOnly restricts the commit summary to the default branch of each repository.
+	// Otherwise every branch is scanned and commits shared by several branches are counted once.
+	DefaultBranchOnly bool
+
+	// RepositoryCache, when set, keeps the repository listing of each organization during
+	// RepositoryCacheTTL (DefaultCacheTTL with NewActivityReport) so that following runs skip it.
+	// Result.CachedListings and Result.SavedQueries measure the cache hits of a run.
+	RepositoryCache    RepositoryCache
+	RepositoryCacheTTL time.Duration
+
+	// Checkpoints, when set, stores the result of each repository as soon as it is reported.
+	// A following run over the same window, with the same filters, skips the repositories stored
+	// and reuses their results, e.g. to resume a scan interrupted by a crash. The results are
+	// cleared once a run completes the scan.
+	Checkpoints CheckpointStore
+
+	// TokenSource provides the OAuth2 tokens used to authenticate to GitHub, e.g. refreshable
+	// GitHub App installation tokens. When nil, the token given to NewActivityReport is used.
+	TokenSource oauth2.TokenSource
+
+	// HTTPClient, when set, is used as is to query GitHub: it must handle authentication itself.
+	// It takes precedence over TokenSource.
+	HTTPClient *http.Client
+
+	// Transport, when set, is the base transport of the client authenticated with TokenSource,
+	// e.g. an *http.Transport configured with a proxy or custom root CAs. It is ignored when
+	// HTTPClient is set.
+	Transport http.RoundTripper
+
+	// UserAgent is the User-Agent header sent to GitHub. Defaults to DefaultUserAgent.
+	UserAgent string
+
+	// Client, when set, is used as is to run the GraphQL queries, e.g. a client wired to a test server.
+	// It takes precedence over BaseURL, HTTPClient, Transport and TokenSource. As its requests don't
+	// go through the transports of the report, Validate rejects UserAgent, RequestID, OnResponse,
+	// AllowPartialData and MaxRetries along with it: set MaxRetries to 0. A *GraphQLError then holds
+	// only the first GraphQL error.
+	Client *graphql.Client
+
+	// Result holds the outcome of the last call to Run
+	Result Result
+
+	// TotalRepositories holds the number of repositories of the organizations found by the last
+	// call to Run, and ScannedRepositories the number of them actually reported, e.g. to show
+	// "scanned 10 of 312". Repositories filtered out, skipped or not reached are not scanned.
+	TotalRepositories   int
+	ScannedRepositories int
+
+	// LastRateLimit holds the GitHub rate limit returned by the most recent query
+	LastRateLimit RateLimit
+
+	// Anonymize replaces the logins of authors and participants with stable pseudonyms
+	// ("user1", "user2"...) in rendered and exported reports. AnonymizeLogin, when set,
+	// is used instead to map each login. Result itself is left untouched.
+	Anonymize      bool
+	AnonymizeLogin func(login string) string
 
 	// Log is called with various debug information.
 	// To log to standard out, use:
 	//  report.Log = func(s string) { log.Println(s) }
+	// Log and Logger are optional: when both are nil, nothing is logged.
 	Log func(s string)
 
-	gitHubToken string
-}
+	// Logger receives the same messages as Log with a severity level, e.g. a *slog.Logger:
+	//  report.Logger = slog.Default()
+	// Warnings are used for low rate limit credits, retries and skipped repositories.
+	Logger Logger
 
-// NewActivityReport makes a new Report to extract data from GitHub.
-func NewActivityReport(org string, token string, duration int) *ActivityReport {
-	report := &ActivityReport{
-		Organization: org,
-		gitHubToken:  token,
-		Duration:     duration,
-	}
+	// RequestID, when set, identifies the report in the logs and in GitHub's: messages passed to
+	// Log are prefixed with "[<RequestID>] ", Logger receives it as a "requestID" attribute, and it
+	// is sent in the X-Request-ID header of every query.
+	RequestID string
+
+	// OnProgress is called before each repository is reported, with the number of repositories
+	// started so far (including this one) and the total number of repositories to report.
+	// It is never called concurrently.
+	OnProgress func(done, total int, repo string)
+
+	// Reporter, when set, publishes the result at the end of Run, e.g. a *FileReporter or an
+	// *HTTPReporter. Incomplete results are not published, and logins are anonymized as in the
+	// exports when Anonymize or AnonymizeLogin is set.
+	Reporter Reporter
+
+	// OnRepositoryReport is called once per repository reported successfully, as soon as its pull
+	// requests are classified, with the merged, open with activity and open without activity ones.
+	// It allows streaming results while the scan goes on. It is never called concurrently.
+	OnRepositoryReport func(repo string, merged, openActive, openIdle []PRStruct)
+
+	// OnResponse, when set, receives the raw JSON body of each GraphQL response,
+	// to diagnose fields that don't populate as expected. It is never called concurrently.
+	OnResponse func(body []byte)
+
+	gitHubToken string
+
+	logMu sync.Mutex
+
+	responseMu sync.Mutex
+
+	paceMu        sync.Mutex
+	nextRequestAt time.Time
+
+	rateLimitMu   sync.Mutex
+	rateLimitSeen bool
+	creditsUsed   int
+
+	// clientMu guards the GraphQL client reused across runs, the transport it owns
+	// and the configuration it was created with
+	clientMu        sync.Mutex
+	client          *graphql.Client
+	clientTransport *http.Transport
+	clientConfig    clientConfig
+}
+
+// NewActivityReport makes a new Report to extract data from GitHub.
+//
+// Deprecated: use New with WithDuration, which scales better as options are added.
+func NewActivityReport(org string, token string, duration int) *ActivityReport {
+	report := &ActivityReport{
+		Organization:       org,
+		gitHubToken:        token,
+		Duration:           duration,
+		BaseURL:            DefaultBaseURL,
+		FullScan:           true,
+		PageSize:           DefaultPageSize,
+		MaxRetries:         DefaultMaxRetries,
+		RetryDelay:         DefaultRetryDelay,
+		Concurrency:        DefaultConcurrency,
+		RepositoryCacheTTL: DefaultCacheTTL,
+		ActivityThreshold:  DefaultActivityThreshold,
+		CountRecentCommits: true,
+		MergedOrder:        DefaultMergedOrder,
+	}
 	return report
 }
 
-// listRepositories queries GitHub and returns the full list of repositories owned by an organization
-func (gr *ActivityReport) listRepositories(
-	ctx context.Context,
-	client *graphql.Client,
-	organization string,
-	cursor string) ([]string, error) {
+// NewActivityReportWithTokenSource makes a new Report authenticated with tokens from ts,
+// e.g. short-lived GitHub App installation tokens refreshed by the caller.
+func NewActivityReportWithTokenSource(org string, ts oauth2.TokenSource, duration int) *ActivityReport {
+	report := NewActivityReport(org, "", duration)
+	report.TokenSource = ts
+	return report
+}
+
+// NewMultiOrganizationReport makes a new Report aggregating several organizations.
+// Organization is set to the first one.
+func NewMultiOrganizationReport(orgs []string, token string, duration int) *ActivityReport {
+	report := NewActivityReport("", token, duration)
+	if len(orgs) > 0 {
+		report.Organization = orgs[0]
+	}
+	report.Organizations = orgs
+	return report
+}
+
+// validateClient rejects the options that rely on the transports of the report when Client is set,
+// as its requests don't go through them
+func (gr *ActivityReport) validateClient() error {
+	if gr.Client == nil {
+		return nil
+	}
+	for _, option := range []struct {
+		name string
+		set  bool
+	}{
+		{"UserAgent", gr.UserAgent != ""},
+		{"RequestID", gr.RequestID != ""},
+		{"OnResponse", gr.OnResponse != nil},
+		{"AllowPartialData", gr.AllowPartialData},
+		// HTTP errors and Retry-After headers are only seen by the transports
+		{"MaxRetries", gr.MaxRetries > 0},
+	} {
+		if option.set {
+			return fmt.Errorf("%s can't be used with Client", option.name)
+		}
+	}
+	return nil
+}
+
+// Validate checks the report parameters. It is called at the start of Run, and the errors
+// name the offending field.
+func (gr *ActivityReport) Validate() error {
+	if len(gr.Organizations) == 0 && gr.Organization == "" {
+		return errors.New("Organization must not be empty")
+	}
+	for _, organization := range gr.Organizations {
+		if organization == "" {
+			return errors.New("Organizations must not contain an empty organization")
+		}
+	}
+	if gr.gitHubToken == "" && gr.TokenSource == nil && gr.HTTPClient == nil && gr.Client == nil {
+		return errors.New("token must not be empty unless TokenSource, HTTPClient or Client is set")
+	}
+	if gr.StartDate.IsZero() && gr.Duration <= 0 {
+		return fmt.Errorf("Duration must be positive when StartDate is not set, got %d", gr.Duration)
+	}
+	if err := gr.validateBaseURL(); err != nil {
+		return err
+	}
+	if gr.PageSize < 1 || gr.PageSize > MaxPageSize {
+		return fmt.Errorf("PageSize must be between 1 and %d, got %d", MaxPageSize, gr.PageSize)
+	}
+	if gr.Concurrency < 0 {
+		return fmt.Errorf("Concurrency must not be negative, got %d", gr.Concurrency)
+	}
+	if gr.BatchSize < 0 || gr.BatchSize > MaxBatchSize {
+		return fmt.Errorf("BatchSize must be between 0 and %d, got %d", MaxBatchSize, gr.BatchSize)
+	}
+	if gr.MaxRetries < 0 || gr.RetryDelay < 0 {
+		return errors.New("MaxRetries and RetryDelay must not be negative")
+	}
+	if err := gr.validateClient(); err != nil {
+		return err
+	}
+	if gr.RequestTimeout < 0 {
+		return errors.New("RequestTimeout must not be negative")
+	}
+	if gr.RequestInterval < 0 {
+		return errors.New("RequestInterval must not be negative")
+	}
+	if gr.StaleAfter < 0 {
+		return errors.New("StaleAfter must not be negative")
+	}
+	if gr.StartDate.IsZero() && !gr.EndDate.IsZero() {
+		return errors.New("EndDate requires StartDate to be set")
+	}
+	if !gr.StartDate.IsZero() && !gr.EndDate.IsZero() && !gr.EndDate.After(gr.StartDate) {
+		return fmt.Errorf("EndDate (%v) must be after StartDate (%v)", gr.EndDate, gr.StartDate)
+	}
+	switch gr.Visibility {
+	case "", VisibilityAll, VisibilityPublic, VisibilityPrivate, VisibilityInternal:
+	default:
+		return fmt.Errorf("Visibility contains an unknown value %q", gr.Visibility)
+	}
+	for state, bucket := range gr.StateMapping {
+		switch state {
+		case StateOpen, StateClosed, StateMerged:
+		default:
+			return fmt.Errorf("StateMapping contains an unknown state %q", state)
+		}
+		switch bucket {
+		case BucketMerged, BucketOpen, BucketClosed, BucketIgnore:
+		default:
+			return fmt.Errorf("StateMapping contains an unknown bucket %q for %s", bucket, state)
+		}
+		// Open pull requests have no merge or close date to bound them to the window
+		if state == StateOpen && (bucket == BucketMerged || bucket == BucketClosed) {
+			return fmt.Errorf("StateMapping can't map %s to %s", state, bucket)
+		}
+	}
+	if gr.MergedOrder != (PROrder{}) {
+		if gr.MergedOrder.Field != "CREATED_AT" && gr.MergedOrder.Field != "UPDATED_AT" {
+			return fmt.Errorf("MergedOrder contains an unknown field %q", gr.MergedOrder.Field)
+		}
+		if gr.MergedOrder.Direction != "DESC" {
+			return fmt.Errorf("MergedOrder must be in DESC direction to start with the window, got %q", gr.MergedOrder.Direction)
+		}
+	}
+	switch gr.AccountType {
+	case "", AccountOrganization, AccountUser:
+	default:
+		return fmt.Errorf("AccountType must be %s or %s, got %q", AccountOrganization, AccountUser, gr.AccountType)
+	}
+	for _, affiliation := range gr.Affiliations {
+		switch affiliation {
+		case AffiliationOwner, AffiliationCollaborator, AffiliationOrganizationMember:
+		default:
+			return fmt.Errorf("Affiliations contains an unknown value %q", affiliation)
+		}
+	}
+	if err := validatePatterns("IncludeRepos", gr.IncludeRepos); err != nil {
+		return err
+	}
+	if err := validatePatterns("ExcludeRepos", gr.ExcludeRepos); err != nil {
+		return err
+	}
+	if err := validatePatterns("BotPatterns", gr.BotPatterns); err != nil {
+		return err
+	}
+	if err := validatePatterns("PathFilter", gr.PathFilter); err != nil {
+		return err
+	}
+	return nil
+}
+
+// validateBaseURL checks that BaseURL is an absolute http(s) URL
+func (gr *ActivityReport) validateBaseURL() error {
+	if gr.BaseURL == "" {
+		return errors.New("BaseURL must not be empty")
+	}
+	u, err := url.Parse(gr.BaseURL)
+	if err != nil {
+		return fmt.Errorf("BaseURL %q is not a valid URL: %v", gr.BaseURL, err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("BaseURL %q must be an absolute http or https URL", gr.BaseURL)
+	}
+	return nil
+}
+
+// ownerQuery adapts a repository listing query to AccountType,
+// listing the repositories of a user instead of an organization when AccountType is AccountUser
+func (gr *ActivityReport) ownerQuery(query string) string {
+	if gr.AccountType != AccountUser {
+		return query
+	}
+	return strings.Replace(query, "organization(login:$organization)", "user(login:$organization)", 1)
+}
 
-	var req *graphql.Request
-	if cursor == "" {
-		req = graphql.NewRequest(`
-  query ($organization: String!, $size: Int!) {
+// affiliations returns the repository affiliations to list, OWNER by default
+func (gr *ActivityReport) affiliations() []string {
+	if len(gr.Affiliations) == 0 {
+		return []string{AffiliationOwner}
+	}
+	return gr.Affiliations
+}
+
+// DefaultRepositoriesQuery lists the repositories of an organization, one page at a time.
+// The same query serves every page so that all pages carry the same fields and arguments:
+// $cursor is left unset (null) for the first page.
+const DefaultRepositoriesQuery = `
+  query ($organization: String!, $size: Int!, $cursor: String, $affiliations: [RepositoryAffiliation]) {
     organization(login:$organization) {
-      repositories(first:$size, affiliations:OWNER) {
+      repositories(first:$size, after:$cursor, affiliations:$affiliations) {
         nodes {
           name
           owner {
             login
           }
+          pushedAt
+          visibility
         }
         pageInfo {
           hasNextPage
@@ -204,130 +1205,89 @@ func (gr *ActivityReport) listRepositories(
       resetAt
     }
   }
-    `)
-	} else {
-		req = graphql.NewRequest(`
-    query ($organization: String!, $size: Int!, $cursor: String!) {
-      organization(login:$organization) {
-        repositories(first:$size, after:$cursor) {
-          nodes {
-            name
-          }
-          pageInfo {
-            hasNextPage
-            endCursor
-          }
-          totalCount
-        }
-      }
-      rateLimit {
-        limit
-        cost
-        remaining
-        resetAt
-      }
-    }
-      `)
+    `
+
+// listRepositories queries GitHub and returns the full list of repositories owned by an organization
+func (gr *ActivityReport) listRepositories(
+	ctx context.Context,
+	client *graphql.Client,
+	organization string,
+	cursor string) ([]RepositoryStruct, error) {
+
+	req := graphql.NewRequest(gr.ownerQuery(gr.repositoriesQuery()))
+	if cursor != "" {
 		req.Var("cursor", cursor)
 	}
 	req.Var("organization", organization)
-	req.Var("size", 50)
+	req.Var("size", gr.PageSize)
+	req.Var("affiliations", gr.affiliations())
 
-	repositories := []string{}
+	repositories := []RepositoryStruct{}
 	var respData repositoriesResponseStruct
-	if err := client.Run(ctx, req, &respData); err != nil {
-		return nil, err
+	if err := gr.runQuery(ctx, client, req, &respData); err != nil {
+		return nil, organizationNotFound(organization, err)
+	} else if owner := respData.owner(); owner == nil {
+		return nil, fmt.Errorf("%w: %s", ErrOrganizationNotFound, organization)
 	} else {
-		for _, repo := range respData.Organization.Repositories.Nodes {
-			repositories = append(repositories, repo.Name)
-		}
-		if respData.Organization.Repositories.PageInfo.HasNextPage {
-			additionalRepos, err := gr.listRepositories(ctx, client, organization, respData.Organization.Repositories.PageInfo.EndCursor)
+		repositories = append(repositories, owner.Repositories.Nodes...)
+		gr.recordRateLimit(respData.RateLimit)
+		if owner.Repositories.PageInfo.HasNextPage {
+			additionalRepos, err := gr.listRepositories(ctx, client, organization, owner.Repositories.PageInfo.EndCursor)
 			if err != nil {
 				return nil, err
 			} else {
 				repositories = append(repositories, additionalRepos...)
 			}
 		}
-		gr.logf("Credits remaining %v\n", respData.RateLimit.Remaining)
 		return repositories, nil
 	}
 }
 
-// listSubsetRepositories returns a subset of repositories owned by an organization
+// Size of the subset of repositories listed when FullScan is false:
+// up to subsetPages pages of subsetPageSize repositories
+const (
+	subsetPageSize = 10
+	subsetPages    = 2
+)
+
+// listSubsetRepositories returns a subset of repositories owned by an organization,
+// and the total number of repositories of the organization.
+// It lists the repositories with the same query as listRepositories, following at most subsetPages pages.
 // It's mainly used for testing purpose in order to reduce the time spent to retrieve the full list
 func (gr *ActivityReport) listSubsetRepositories(
 	ctx context.Context,
 	client *graphql.Client,
-	organization string,
-	cursor string) ([]string, error) {
+	organization string) ([]RepositoryStruct, int, error) {
 
-	var req *graphql.Request
-	if cursor == "" {
-		req = graphql.NewRequest(`
-  query ($organization: String!, $size: Int!) {
-    organization(login:$organization) {
-      repositories(last:$size, affiliations:OWNER) {
-        nodes {
-          name
-          owner {
-            login
-          }
-        }
-        pageInfo {
-          hasNextPage
-          endCursor
-        }
-        totalCount
-      }
-    }
-    rateLimit {
-      limit
-      cost
-      remaining
-      resetAt
-    }
-  }
-    `)
-	} else {
-		req = graphql.NewRequest(`
-    query ($organization: String!, $size: Int!, $cursor: String!) {
-      organization(login:$organization) {
-        repositories(first:$size, after:$cursor) {
-          nodes {
-            name
-          }
-          pageInfo {
-            hasNextPage
-            endCursor
-          }
-          totalCount
-        }
-      }
-      rateLimit {
-        limit
-        cost
-        remaining
-        resetAt
-      }
-    }
-      `)
-		req.Var("cursor", cursor)
-	}
-	req.Var("organization", organization)
-	req.Var("size", 10)
+	repositories := []RepositoryStruct{}
+	total := 0
+	cursor := ""
+	for page := 0; page < subsetPages; page++ {
+		req := graphql.NewRequest(gr.ownerQuery(gr.repositoriesQuery()))
+		if cursor != "" {
+			req.Var("cursor", cursor)
+		}
+		req.Var("organization", organization)
+		req.Var("size", subsetPageSize)
+		req.Var("affiliations", gr.affiliations())
 
-	repositories := []string{}
-	var respData repositoriesResponseStruct
-	if err := client.Run(ctx, req, &respData); err != nil {
-		return nil, err
-	} else {
-		for _, repo := range respData.Organization.Repositories.Nodes {
-			repositories = append(repositories, repo.Name)
+		var respData repositoriesResponseStruct
+		if err := gr.runQuery(ctx, client, req, &respData); err != nil {
+			return nil, 0, organizationNotFound(organization, err)
 		}
-		gr.logf("Credits remaining %v\n", respData.RateLimit.Remaining)
-		return repositories, nil
+		owner := respData.owner()
+		if owner == nil {
+			return nil, 0, fmt.Errorf("%w: %s", ErrOrganizationNotFound, organization)
+		}
+		gr.recordRateLimit(respData.RateLimit)
+		repositories = append(repositories, owner.Repositories.Nodes...)
+		total = owner.Repositories.TotalCount
+		if !owner.Repositories.PageInfo.HasNextPage {
+			break
+		}
+		cursor = owner.Repositories.PageInfo.EndCursor
 	}
+	return repositories, total, nil
 }
 
 // reportRepository creates the report for 1 repository
@@ -339,81 +1299,30 @@ func (gr *ActivityReport) reportRepository(
 	since time.Time) (reportResponseStruct, error) {
 
 	// make a request
-	req := graphql.NewRequest(`
-query ($organization: String!, $repo: String!, $date: GitTimestamp!, $date2: DateTime!, $size: Int!) {
+	req := graphql.NewRequest(gr.repositoryQuery())
+
+	// set any variables
+	req.Var("organization", organization)
+	req.Var("repo", repository)
+	gr.setReportVariables(req, since)
+
+	// run it and capture the response
+	var respData reportResponseStruct
+	if err := gr.runQuery(ctx, client, req, &respData); err != nil {
+		return respData, err
+	} else {
+		gr.recordRateLimit(respData.RateLimit)
+		err := gr.completeRepository(ctx, client, organization, repository, since, &respData.Repository)
+		return respData, err
+	}
+}
+
+// DefaultRepositoryQuery reports one repository: its pull requests, issues and branches.
+// Its variables are set by reportRepository and setReportVariables.
+const DefaultRepositoryQuery = `
+query ($organization: String!, $repo: String!, ` + reportVariables + `) {
   repository(owner: $organization, name: $repo) {
-    name
-    mergedPR: pullRequests(last: $size, states: [MERGED], orderBy: {field: UPDATED_AT, direction: ASC}) {
-      nodes {
-        number
-        title
-        createdAt
-        participants(last: $size) {
-          nodes {
-            login
-          }
-          totalCount
-        }
-        mergedAt
-      }
-      totalCount
-    }
-    openPR: pullRequests(last: $size, states: [OPEN]) {
-      nodes {
-        number
-        title
-        createdAt
-        mergedAt
-        state
-        participants(last: $size) {
-          nodes {
-            login
-          }
-          totalCount
-        }
-        timeline(since: $date2) {
-          totalCount
-        }
-      }
-      pageInfo {
-        hasNextPage
-        endCursor
-      }
-      totalCount
-    }
-    refs(refPrefix: "refs/heads/", first: $size) {
-      nodes {
-        ... on Ref {
-          name
-          target {
-            ... on Commit {
-              history(first: $size, since: $date) {
-                nodes {
-                  ... on Commit {
-                    oid
-                    committedDate
-                    author {
-                      name
-                    }
-                    message
-                  }
-                }
-                pageInfo {
-                  hasNextPage
-                  endCursor
-                }
-                totalCount
-              }
-            }
-          }
-        }
-      }
-      pageInfo {
-        hasNextPage
-        endCursor
-      }
-      totalCount
-    }
+    ...repositoryFields
   }
   rateLimit {
     limit
@@ -422,80 +1331,1480 @@ query ($organization: String!, $repo: String!, $date: GitTimestamp!, $date2: Dat
     resetAt
   }
 }
-  `)
-
-	// set any variables
-	req.Var("organization", organization)
-	req.Var("repo", repository)
-	req.Var("date", since.Format(ISO_FORM))
-	req.Var("date2", since.Format(ISO_FORM))
-	req.Var("size", 50)
+` + repositoryFieldsFragment
 
-	// run it and capture the response
-	var respData reportResponseStruct
-	if err := client.Run(ctx, req, &respData); err != nil {
-		return respData, err
-	} else {
-		gr.logf("Credits remaining %v\n", respData.RateLimit.Remaining)
-		return respData, nil
+// repositoryQuery returns RepositoryQuery, or DefaultRepositoryQuery when it is not set
+func (gr *ActivityReport) repositoryQuery() string {
+	if gr.RepositoryQuery == "" {
+		return DefaultRepositoryQuery
 	}
+	return gr.RepositoryQuery
 }
 
-func (gr *ActivityReport) logf(format string, args ...interface{}) {
-	gr.Log(fmt.Sprintf(format, args...))
+// repositoriesQuery returns RepositoriesQuery, or DefaultRepositoriesQuery when it is not set
+func (gr *ActivityReport) repositoriesQuery() string {
+	if gr.RepositoriesQuery == "" {
+		return DefaultRepositoriesQuery
+	}
+	return gr.RepositoriesQuery
 }
 
-// Run extracts the report from GitHub GraphQL API
-func (gr *ActivityReport) Run() error {
+// reportBatch creates the report for several repositories with a single query,
+// each repository being queried under the alias repo0, repo1...
+func (gr *ActivityReport) reportBatch(
+	ctx context.Context,
+	client *graphql.Client,
+	repositories []repositoryRef,
+	since time.Time) ([]repositoryReportStruct, error) {
 
-	// create a client (safe to share across requests)
-	ctx := context.Background()
-	tokenSource := oauth2.StaticTokenSource(
-		&oauth2.Token{AccessToken: gr.gitHubToken},
-	)
-	httpClient := oauth2.NewClient(ctx, tokenSource)
-	client := graphql.NewClient("https://api.github.com/graphql", graphql.WithHTTPClient(httpClient), graphql.UseInlineJSON())
-	//client.Log = func(s string) { fmt.Println(s) }
+	var declarations, selections strings.Builder
+	for i := range repositories {
+		fmt.Fprintf(&declarations, "$org%d: String!, $repo%d: String!, ", i, i)
+		fmt.Fprintf(&selections, "  repo%d: repository(owner: $org%d, name: $repo%d) {\n    ...repositoryFields\n  }\n", i, i, i)
+	}
+	req := graphql.NewRequest(`
+query (` + declarations.String() + reportVariables + `) {
+` + selections.String() + `  rateLimit {
+    limit
+    cost
+    remaining
+    resetAt
+  }
+}
+` + repositoryFieldsFragment)
+	for i, repo := range repositories {
+		req.Var(fmt.Sprintf("org%d", i), repo.Organization)
+		req.Var(fmt.Sprintf("repo%d", i), repo.Name)
+	}
+	gr.setReportVariables(req, since)
 
-	now := time.Now()
-	since := now.AddDate(0, 0, -gr.Duration)
+	var respData map[string]json.RawMessage
+	if err := gr.runQuery(ctx, client, req, &respData); err != nil {
+		return nil, err
+	}
+	var rateLimit RateLimitStruct
+	if data, ok := respData["rateLimit"]; ok {
+		if err := json.Unmarshal(data, &rateLimit); err != nil {
+			return nil, err
+		}
+	}
+	gr.recordRateLimit(rateLimit)
 
-	gr.ReportDate = now
+	reports := make([]repositoryReportStruct, len(repositories))
+	for i, repo := range repositories {
+		// A repository missing from the response (or null) must not be reported as empty
+		data, ok := respData[fmt.Sprintf("repo%d", i)]
+		if !ok || string(data) == "null" {
+			return nil, &RepositoryError{Organization: repo.Organization, Repository: repo.Name,
+				Err: fmt.Errorf("No data returned for %s/%s", repo.Organization, repo.Name)}
+		}
+		if err := json.Unmarshal(data, &reports[i]); err != nil {
+			return nil, err
+		}
+		if err := gr.completeRepository(ctx, client, repo.Organization, repo.Name, since, &reports[i]); err != nil {
+			return nil, err
+		}
+	}
+	return reports, nil
+}
 
-	repositories, err := gr.listRepositories(ctx, client, gr.Organization, "")
-	if err != nil {
-		return errors.New(fmt.Sprintf("An error occured during repositories listing %v\n", err))
+// setReportVariables sets the variables declared by reportVariables
+func (gr *ActivityReport) setReportVariables(req *graphql.Request, since time.Time) {
+	req.Var("date", since.UTC().Format(ISO_FORM))
+	req.Var("date2", since.UTC().Format(ISO_FORM))
+	req.Var("size", gr.PageSize)
+	req.Var("defaultBranchOnly", gr.DefaultBranchOnly)
+	req.Var("mergedOrder", gr.mergedOrder())
+	if gr.BaseBranch != "" {
+		req.Var("baseBranch", gr.BaseBranch)
 	} else {
-		for _, repoName := range repositories {
-			report, err2 := gr.reportRepository(ctx, client, gr.Organization, repoName, since)
-			if err2 != nil {
-				return errors.New(fmt.Sprintf("An error occured during report for %s: %v\n", repoName, err2))
-			} else {
-				// Build report
-
-				// Extract Merged PR (keep the ones merged during last 7 days)
-				for _, pullrequest := range report.Repository.MergedPR.Nodes {
-					t, _ := time.Parse(ISO_FORM, pullrequest.MergedAt)
-					if t.After(since) {
-						pullrequest.Repository = repoName
-						gr.Result.MergedPRs = append(gr.Result.MergedPRs, pullrequest)
-					}
-				}
+		req.Var("baseBranch", nil)
+	}
+	req.Var("queryMerged", gr.bucketOf(StateMerged) != BucketIgnore)
+	req.Var("queryOpen", gr.bucketOf(StateOpen) != BucketIgnore)
+	req.Var("queryClosed", gr.bucketOf(StateClosed) != BucketIgnore)
+}
 
-				// Extract Open PR with and without activity
-				for _, pullrequest := range report.Repository.OpenPR.Nodes {
-					pullrequest.Repository = repoName
-					if pullrequest.Timeline.TotalCount > 0 {
-						gr.Result.OpenPRsWithActivity = append(gr.Result.OpenPRsWithActivity, pullrequest)
-					} else {
-						gr.Result.OpenPRsWithoutActivity = append(gr.Result.OpenPRsWithoutActivity, pullrequest)
-					}
-				}
+// completeRepository associates the pull requests of a repository report with the repository
+// and fetches the pages of merged pull requests, issues, participants and branches beyond the first one
+func (gr *ActivityReport) completeRepository(
+	ctx context.Context,
+	client *graphql.Client,
+	organization string,
+	repository string,
+	since time.Time,
+	report *repositoryReportStruct) error {
+
+	if err := gr.completeMerged(ctx, client, organization, repository, since, report); err != nil {
+		return err
+	}
+	if err := gr.completeIssues(ctx, client, organization, repository, since, report); err != nil {
+		return err
+	}
+	setRepository(report.MergedPR.Nodes, organization, repository)
+	setRepository(report.OpenPR.Nodes, organization, repository)
+	setRepository(report.ClosedPR.Nodes, organization, repository)
+	for _, pullrequests := range [][]PRStruct{
+		report.MergedPR.Nodes,
+		report.OpenPR.Nodes,
+		report.ClosedPR.Nodes,
+	} {
+		for i := range pullrequests {
+			if err := gr.completeParticipants(ctx, client, organization, repository, &pullrequests[i]); err != nil {
+				return err
 			}
 		}
-		gr.logf("Nb merged pr:%d\n", len(gr.Result.MergedPRs))
-		gr.logf("Nb open pr with activity:%d\n", len(gr.Result.OpenPRsWithActivity))
-		gr.logf("Nb open pr without activity:%d\n", len(gr.Result.OpenPRsWithoutActivity))
-		return nil
 	}
+	if !gr.DefaultBranchOnly {
+		return gr.completeRefs(ctx, client, organization, repository, since, report)
+	}
+	return nil
+}
+
+// reportVariables declares the variables used by repositoryFieldsFragment, set by setReportVariables
+const reportVariables = `$date: GitTimestamp!, $date2: DateTime!, $size: Int!, $defaultBranchOnly: Boolean!, $mergedOrder: IssueOrder!, $baseBranch: String, $queryMerged: Boolean!, $queryOpen: Boolean!, $queryClosed: Boolean!`
+
+// repositoryFieldsFragment selects the pull requests, issues and branches of a reported repository
+const repositoryFieldsFragment = `
+fragment repositoryFields on Repository {
+  name
+  mergedPR: pullRequests(first: $size, states: [MERGED], orderBy: $mergedOrder, baseRefName: $baseBranch) @include(if: $queryMerged) {
+    nodes {
+      ...prFields
+      mergedAt
+      mergeCommitOid: mergeCommit {
+        oid
+      }
+    }
+    pageInfo {
+      hasNextPage
+      endCursor
+    }
+    totalCount
+  }
+  openPR: pullRequests(last: $size, states: [OPEN], baseRefName: $baseBranch) @include(if: $queryOpen) {
+    nodes {
+      ...prFields
+      mergedAt
+      state
+      isDraft
+      mergeable
+      timeline(since: $date2) {
+        totalCount
+      }
+      activity: timelineItems(since: $date2, itemTypes: [PULL_REQUEST_COMMIT, ISSUE_COMMENT, PULL_REQUEST_REVIEW]) {
+        totalCount
+      }
+      lastCommitAt: commits(last: 1) {
+        nodes {
+          commit {
+            committedDate
+          }
+        }
+      }
+      ...recentCommentsFields
+    }
+    pageInfo {
+      hasNextPage
+      endCursor
+    }
+    totalCount
+  }
+  closedPR: pullRequests(last: $size, states: [CLOSED], orderBy: {field: UPDATED_AT, direction: ASC}, baseRefName: $baseBranch) @include(if: $queryClosed) {
+    nodes {
+      ...prFields
+      closedAt
+      state
+    }
+    totalCount
+  }
+  issues(first: $size, states: [OPEN, CLOSED], filterBy: {since: $date2}, orderBy: {field: UPDATED_AT, direction: DESC}) {
+    nodes {
+      ...issueFields
+    }
+    pageInfo {
+      hasNextPage
+      endCursor
+    }
+    totalCount
+  }
+  refs(refPrefix: "refs/heads/", first: $size) @skip(if: $defaultBranchOnly) {
+    nodes {
+      ...refFields
+    }
+    pageInfo {
+      hasNextPage
+      endCursor
+    }
+    totalCount
+  }
+  defaultBranchRef @include(if: $defaultBranchOnly) {
+    ...refFields
+  }
+}
+` + prFieldsFragment + recentCommentsFragment + refFieldsFragment + issueFieldsFragment
+
+// mergedOrder returns MergedOrder, or DefaultMergedOrder when it is not set
+func (gr *ActivityReport) mergedOrder() PROrder {
+	if gr.MergedOrder == (PROrder{}) {
+		return DefaultMergedOrder
+	}
+	return gr.MergedOrder
+}
+
+// prFieldsFragment selects the fields common to every pull request queried
+const prFieldsFragment = `
+fragment prFields on PullRequest {
+  number
+  title
+  createdAt
+  updatedAt
+  author {
+    login
+  }
+  participants(first: $size) {
+    nodes {
+      login
+    }
+    pageInfo {
+      hasNextPage
+      endCursor
+    }
+    totalCount
+  }
+  labels(first: 10) {
+    nodes {
+      name
+    }
+  }
+  reviews(last: $size) {
+    totalCount
+  }
+  firstReviewAt: reviews(first: 1) {
+    nodes {
+      submittedAt
+    }
+  }
+  reviewDecision
+  requestedReviewers: reviewRequests(first: $size) {
+    nodes {
+      requestedReviewer {
+        ... on User {
+          login
+        }
+        ... on Team {
+          slug
+        }
+      }
+    }
+  }
+  additions
+  deletions
+  changedFiles: files(first: $size) {
+    nodes {
+      path
+    }
+  }
+  changedFileCount: changedFiles
+}
+`
+
+// recentCommentsFragment counts the general and the code review comments posted on a pull request
+// since the beginning of the report window, decoded in RecentCommentCount and RecentReviewCommentCount
+const recentCommentsFragment = `
+fragment recentCommentsFields on PullRequest {
+  recentCommentCount: timelineItems(since: $date2, itemTypes: [ISSUE_COMMENT]) {
+    totalCount
+  }
+  recentReviewCommentCount: timelineItems(last: $size, since: $date2, itemTypes: [PULL_REQUEST_REVIEW]) {
+    nodes {
+      ... on PullRequestReview {
+        comments {
+          totalCount
+        }
+      }
+    }
+  }
+}
+`
+
+// mergedResponseStruct defines the structure sent by GitHub GraphQL API for a page of merged pull requests
+type mergedResponseStruct struct {
+	Repository struct {
+		MergedPR struct {
+			Nodes      []PRStruct
+			PageInfo   PageInfoStruct
+			TotalCount int
+		}
+	}
+	RateLimit RateLimitStruct
+}
+
+// completeMerged fetches the following pages of merged pull requests until they were last
+// updated before since: a pull request merged during the window was updated since then.
+// Pages are only followed with the UPDATED_AT order, the only one bounding the window: with
+// another order, a warning tells that the merged pull requests beyond the first page are missed.
+func (gr *ActivityReport) completeMerged(
+	ctx context.Context,
+	client *graphql.Client,
+	organization string,
+	repository string,
+	since time.Time,
+	report *repositoryReportStruct) error {
+
+	merged := &report.MergedPR
+	if gr.mergedOrder().Field != "UPDATED_AT" {
+		if merged.PageInfo.HasNextPage {
+			gr.warnf("Only the first %d merged pull requests of %s/%s are reported with MergedOrder %s\n",
+				len(merged.Nodes), organization, repository, gr.mergedOrder().Field)
+		}
+		return nil
+	}
+	for merged.PageInfo.HasNextPage && len(merged.Nodes) > 0 {
+		oldest, err := time.Parse(ISO_FORM, merged.Nodes[len(merged.Nodes)-1].UpdatedAt)
+		if err != nil || oldest.Before(since) {
+			return nil
+		}
+		req := graphql.NewRequest(`
+query ($organization: String!, $repo: String!, $size: Int!, $cursor: String!, $mergedOrder: IssueOrder!, $baseBranch: String) {
+  repository(owner: $organization, name: $repo) {
+    mergedPR: pullRequests(first: $size, after: $cursor, states: [MERGED], orderBy: $mergedOrder, baseRefName: $baseBranch) {
+      nodes {
+        ...prFields
+        mergedAt
+        mergeCommitOid: mergeCommit {
+          oid
+        }
+      }
+      pageInfo {
+        hasNextPage
+        endCursor
+      }
+      totalCount
+    }
+  }
+  rateLimit {
+    limit
+    cost
+    remaining
+    resetAt
+  }
+}
+` + prFieldsFragment)
+		req.Var("organization", organization)
+		req.Var("repo", repository)
+		req.Var("size", gr.PageSize)
+		req.Var("cursor", merged.PageInfo.EndCursor)
+		req.Var("mergedOrder", gr.mergedOrder())
+		if gr.BaseBranch != "" {
+			req.Var("baseBranch", gr.BaseBranch)
+		} else {
+			req.Var("baseBranch", nil)
+		}
+
+		var respData mergedResponseStruct
+		if err := gr.runQuery(ctx, client, req, &respData); err != nil {
+			return err
+		}
+		gr.recordRateLimit(respData.RateLimit)
+		merged.Nodes = append(merged.Nodes, respData.Repository.MergedPR.Nodes...)
+		merged.PageInfo = respData.Repository.MergedPR.PageInfo
+	}
+	return nil
+}
+
+// refFieldsFragment selects a branch and its commit history since $date
+const refFieldsFragment = `
+fragment refFields on Ref {
+  name
+  target {
+    ... on Commit {
+      history(first: $size, since: $date) {
+        nodes {
+          oid
+          committedDate
+          author {
+            name
+            user {
+              login
+            }
+          }
+          message
+        }
+        pageInfo {
+          hasNextPage
+          endCursor
+        }
+        totalCount
+      }
+    }
+  }
+}
+`
+
+// refsResponseStruct defines the structure sent by GitHub GraphQL API for a page of branches
+type refsResponseStruct struct {
+	Repository struct {
+		Refs struct {
+			Nodes      []RefStruct
+			PageInfo   PageInfoStruct
+			TotalCount int
+		}
+	}
+	RateLimit RateLimitStruct
+}
+
+// completeRefs fetches the remaining pages of branches of a repository
+func (gr *ActivityReport) completeRefs(
+	ctx context.Context,
+	client *graphql.Client,
+	organization string,
+	repository string,
+	since time.Time,
+	report *repositoryReportStruct) error {
+
+	refs := &report.Refs
+	for refs.PageInfo.HasNextPage {
+		req := graphql.NewRequest(`
+query ($organization: String!, $repo: String!, $date: GitTimestamp!, $size: Int!, $cursor: String!) {
+  repository(owner: $organization, name: $repo) {
+    refs(refPrefix: "refs/heads/", first: $size, after: $cursor) {
+      nodes {
+        ...refFields
+      }
+      pageInfo {
+        hasNextPage
+        endCursor
+      }
+      totalCount
+    }
+  }
+  rateLimit {
+    limit
+    cost
+    remaining
+    resetAt
+  }
+}
+` + refFieldsFragment)
+		req.Var("organization", organization)
+		req.Var("repo", repository)
+		req.Var("date", since.UTC().Format(ISO_FORM))
+		req.Var("size", gr.PageSize)
+		req.Var("cursor", refs.PageInfo.EndCursor)
+
+		var respData refsResponseStruct
+		if err := gr.runQuery(ctx, client, req, &respData); err != nil {
+			return err
+		}
+		gr.recordRateLimit(respData.RateLimit)
+		refs.Nodes = append(refs.Nodes, respData.Repository.Refs.Nodes...)
+		refs.PageInfo = respData.Repository.Refs.PageInfo
+	}
+	return nil
+}
+
+type participantsResponseStruct struct {
+	Repository struct {
+		PullRequest struct {
+			Participants struct {
+				Nodes      []UserStruct
+				PageInfo   PageInfoStruct
+				TotalCount int
+			}
+		}
+	}
+	RateLimit RateLimitStruct
+}
+
+// completeParticipants fetches the remaining pages of participants of a pull request
+func (gr *ActivityReport) completeParticipants(
+	ctx context.Context,
+	client *graphql.Client,
+	organization string,
+	repository string,
+	pr *PRStruct) error {
+
+	for pr.Participants.PageInfo.HasNextPage {
+		req := graphql.NewRequest(`
+query ($organization: String!, $repo: String!, $number: Int!, $size: Int!, $cursor: String!) {
+  repository(owner: $organization, name: $repo) {
+    pullRequest(number: $number) {
+      participants(first: $size, after: $cursor) {
+        nodes {
+          login
+        }
+        pageInfo {
+          hasNextPage
+          endCursor
+        }
+        totalCount
+      }
+    }
+  }
+  rateLimit {
+    limit
+    cost
+    remaining
+    resetAt
+  }
+}
+  `)
+		req.Var("organization", organization)
+		req.Var("repo", repository)
+		req.Var("number", pr.Number)
+		req.Var("size", gr.PageSize)
+		req.Var("cursor", pr.Participants.PageInfo.EndCursor)
+
+		var respData participantsResponseStruct
+		if err := gr.runQuery(ctx, client, req, &respData); err != nil {
+			return err
+		}
+		gr.recordRateLimit(respData.RateLimit)
+		participants := respData.Repository.PullRequest.Participants
+		pr.Participants.Nodes = append(pr.Participants.Nodes, participants.Nodes...)
+		pr.Participants.PageInfo = participants.PageInfo
+	}
+	return nil
+}
+
+// issueFieldsFragment selects the fields of an issue
+const issueFieldsFragment = `
+fragment issueFields on Issue {
+  number
+  title
+  state
+  createdAt
+  updatedAt
+  closedAt
+  labels(first: 10) {
+    nodes {
+      name
+    }
+  }
+}
+`
+
+// issuesResponseStruct defines the structure sent by GitHub GraphQL API for a page of issues
+type issuesResponseStruct struct {
+	Repository struct {
+		Issues struct {
+			Nodes      []IssueStruct
+			PageInfo   PageInfoStruct
+			TotalCount int
+		}
+	}
+	RateLimit RateLimitStruct
+}
+
+// completeIssues fetches the remaining pages of issues updated since the beginning of the window.
+// With an EndDate in the past, the first pages may only hold issues updated after the window.
+func (gr *ActivityReport) completeIssues(
+	ctx context.Context,
+	client *graphql.Client,
+	organization string,
+	repository string,
+	since time.Time,
+	report *repositoryReportStruct) error {
+
+	issues := &report.Issues
+	for issues.PageInfo.HasNextPage {
+		req := graphql.NewRequest(`
+query ($organization: String!, $repo: String!, $date2: DateTime!, $size: Int!, $cursor: String!) {
+  repository(owner: $organization, name: $repo) {
+    issues(first: $size, after: $cursor, states: [OPEN, CLOSED], filterBy: {since: $date2}, orderBy: {field: UPDATED_AT, direction: DESC}) {
+      nodes {
+        ...issueFields
+      }
+      pageInfo {
+        hasNextPage
+        endCursor
+      }
+      totalCount
+    }
+  }
+  rateLimit {
+    limit
+    cost
+    remaining
+    resetAt
+  }
+}
+` + issueFieldsFragment)
+		req.Var("organization", organization)
+		req.Var("repo", repository)
+		req.Var("date2", since.UTC().Format(ISO_FORM))
+		req.Var("size", gr.PageSize)
+		req.Var("cursor", issues.PageInfo.EndCursor)
+
+		var respData issuesResponseStruct
+		if err := gr.runQuery(ctx, client, req, &respData); err != nil {
+			return err
+		}
+		gr.recordRateLimit(respData.RateLimit)
+		issues.Nodes = append(issues.Nodes, respData.Repository.Issues.Nodes...)
+		issues.PageInfo = respData.Repository.Issues.PageInfo
+	}
+	return nil
+}
+
+// issueInWindow reports whether the issue was created, updated or closed during the window.
+// An issue updated again after the end of the window is kept when it was created or closed in it.
+func issueInWindow(issue IssueStruct, since time.Time, until time.Time) bool {
+	for _, date := range []string{issue.CreatedAt, issue.UpdatedAt, issue.ClosedAt} {
+		if t, err := time.Parse(ISO_FORM, date); err == nil && inWindow(t, since, until) {
+			return true
+		}
+	}
+	return false
+}
+
+// setRepository associates every pull request with the organization and repository it was fetched from
+func setRepository(pullrequests []PRStruct, organization string, repository string) {
+	for i := range pullrequests {
+		pullrequests[i].Org = organization
+		pullrequests[i].Repository = repository
+	}
+}
+
+// Summary returns a one-line summary of the report, e.g.
+//
+//	Org acme | window 7d | merged 12, open+activity 5, open+idle 9
+//
+// It can be called before Run, in which case all counts are zero.
+func (gr *ActivityReport) Summary() string {
+	window := fmt.Sprintf("%dd", gr.Duration)
+	if !gr.StartDate.IsZero() {
+		reportDate := gr.Result.ReportDate
+		if reportDate.IsZero() {
+			reportDate = time.Now().In(gr.location())
+		}
+		since, until := gr.window(reportDate)
+		window = since.Format("2006-01-02") + ".." + until.Format("2006-01-02")
+	}
+	return fmt.Sprintf("Org %s | window %s | merged %d, open+activity %d, open+idle %d",
+		strings.Join(gr.organizations(), ","),
+		window,
+		len(gr.Result.MergedPRs),
+		len(gr.Result.OpenPRsWithActivity),
+		len(gr.Result.OpenPRsWithoutActivity))
+}
+
+// window returns the bounds of the report window: StartDate and EndDate when set,
+// otherwise the last Duration days before now, in Location
+func (gr *ActivityReport) window(now time.Time) (time.Time, time.Time) {
+	location := gr.location()
+	now = now.In(location)
+	if gr.StartDate.IsZero() {
+		return now.AddDate(0, 0, -gr.Duration), now
+	}
+	if gr.EndDate.IsZero() {
+		return gr.StartDate.In(location), now
+	}
+	return gr.StartDate.In(location), gr.EndDate.In(location)
+}
+
+// location returns Location, or UTC when it is not set
+func (gr *ActivityReport) location() *time.Location {
+	if gr.Location == nil {
+		return time.UTC
+	}
+	return gr.Location
+}
+
+// inWindow reports whether t is after since and not after until
+func inWindow(t time.Time, since time.Time, until time.Time) bool {
+	return t.After(since) && !t.After(until)
+}
+
+// clientConfig holds the fields of the report a GraphQL client is created with
+type clientConfig struct {
+	baseURL     string
+	httpClient  *http.Client
+	transport   http.RoundTripper
+	tokenSource oauth2.TokenSource
+}
+
+// equal reports whether both configurations create the same client.
+// Transports and token sources of types that can't be compared are never equal.
+func (c clientConfig) equal(other clientConfig) bool {
+	return c.baseURL == other.baseURL && c.httpClient == other.httpClient &&
+		sameValue(c.transport, other.transport) && sameValue(c.tokenSource, other.tokenSource)
+}
+
+// sameValue compares a and b without panicking on values of types that can't be compared
+func sameValue(a interface{}, b interface{}) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	if reflect.TypeOf(a) != reflect.TypeOf(b) || !reflect.TypeOf(a).Comparable() {
+		return false
+	}
+	return a == b
+}
+
+// newClient returns the GraphQL client used to query GitHub: Client when set, otherwise the one
+// created by a previous run, reused as long as BaseURL, HTTPClient, Transport and TokenSource
+// don't change and Close is not called
+func (gr *ActivityReport) newClient(ctx context.Context) *graphql.Client {
+	if gr.Client != nil {
+		return gr.Client
+	}
+	gr.clientMu.Lock()
+	defer gr.clientMu.Unlock()
+	config := clientConfig{
+		baseURL:     gr.BaseURL,
+		httpClient:  gr.HTTPClient,
+		transport:   gr.Transport,
+		tokenSource: gr.TokenSource,
+	}
+	if gr.client == nil || !gr.clientConfig.equal(config) {
+		gr.closeIdleConnections()
+		gr.client = graphql.NewClient(gr.BaseURL, graphql.WithHTTPClient(gr.newHTTPClient(ctx)), graphql.UseInlineJSON())
+		//client.Log = func(s string) { fmt.Println(s) }
+		gr.clientConfig = config
+	}
+	return gr.client
+}
+
+// Close closes the idle connections kept to GitHub and releases the client reused across runs.
+// Long-lived services running the report on a schedule should call it when they stop.
+// The report can still be run afterwards, with a new client.
+func (gr *ActivityReport) Close() error {
+	gr.clientMu.Lock()
+	defer gr.clientMu.Unlock()
+	gr.closeIdleConnections()
+	gr.client = nil
+	return nil
+}
+
+// closeIdleConnections closes the idle connections of the current client, with clientMu held
+func (gr *ActivityReport) closeIdleConnections() {
+	if gr.clientTransport != nil {
+		gr.clientTransport.CloseIdleConnections()
+		gr.clientTransport = nil
+	}
+	if gr.clientConfig.httpClient != nil {
+		gr.clientConfig.httpClient.CloseIdleConnections()
+	}
+	if closer, ok := gr.clientConfig.transport.(interface{ CloseIdleConnections() }); ok {
+		closer.CloseIdleConnections()
+	}
+}
+
+// newHTTPClient returns the HTTP client used to query GitHub: HTTPClient when set,
+// otherwise an OAuth2 client authenticated with TokenSource or the token given to NewActivityReport.
+// It is called with clientMu held.
+func (gr *ActivityReport) newHTTPClient(ctx context.Context) *http.Client {
+	var httpClient http.Client
+	if gr.HTTPClient != nil {
+		httpClient = *gr.HTTPClient
+	} else {
+		tokenSource := gr.TokenSource
+		if tokenSource == nil {
+			tokenSource = oauth2.StaticTokenSource(
+				&oauth2.Token{AccessToken: gr.gitHubToken},
+			)
+		}
+		transport := gr.Transport
+		if transport == nil {
+			// A transport of its own, so that Close only closes the connections of the report
+			gr.clientTransport = http.DefaultTransport.(*http.Transport).Clone()
+			transport = gr.clientTransport
+		}
+		ctx = context.WithValue(ctx, oauth2.HTTPClient, &http.Client{Transport: transport})
+		httpClient = *oauth2.NewClient(ctx, tokenSource)
+	}
+	base := httpClient.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	base = &responseTransport{base: base, report: gr}
+	base = &userAgentTransport{base: base, report: gr}
+	base = &requestIDTransport{base: base, report: gr}
+	httpClient.Transport = &statusTransport{base: &graphQLErrorsTransport{base: base}}
+	return &httpClient
+}
+
+// isActive reports whether an open pull request had at least ActivityThreshold commits,
+// comments or reviews during the report window starting at since, or, with CountRecentCommits,
+// a last commit dated after since. Other timeline events (labels, subscriptions...)
+// don't count as activity.
+func (gr *ActivityReport) isActive(pr PRStruct, since time.Time) bool {
+	threshold := gr.ActivityThreshold
+	if threshold < 1 {
+		threshold = 1
+	}
+	if pr.Activity.TotalCount >= threshold {
+		return true
+	}
+	if gr.CountRecentCommits {
+		committed, err := time.Parse(ISO_FORM, string(pr.LastCommitAt))
+		return err == nil && committed.After(since)
+	}
+	return false
+}
+
+// isStale tells whether an open pull request has been idle for longer than StaleAfter at now.
+// The last update is used when known, the creation date otherwise.
+func (gr *ActivityReport) isStale(pr PRStruct, since time.Time, now time.Time) bool {
+	if gr.StaleAfter <= 0 || gr.isActive(pr, since) {
+		return false
+	}
+	last, err := time.Parse(ISO_FORM, pr.UpdatedAt)
+	if err != nil {
+		last, err = time.Parse(ISO_FORM, pr.CreatedAt)
+		if err != nil {
+			return false
+		}
+	}
+	return now.Sub(last) > gr.StaleAfter
+}
+
+// PRAge returns the age of a pull request at the report date (see PRStruct.Age).
+// ok is false when the report has not run yet or CreatedAt is unparseable.
+func (gr *ActivityReport) PRAge(pr PRStruct) (age time.Duration, ok bool) {
+	if gr.Result.ReportDate.IsZero() {
+		return 0, false
+	}
+	return pr.Age(gr.Result.ReportDate)
+}
+
+// uniqueCommits removes the commits reachable from several branches, based on their oid
+func uniqueCommits(commits []CommitStruct) []CommitStruct {
+	seen := map[string]bool{}
+	unique := []CommitStruct{}
+	for _, commit := range commits {
+		if seen[commit.Oid] {
+			continue
+		}
+		seen[commit.Oid] = true
+		unique = append(unique, commit)
+	}
+	return unique
+}
+
+// conventionalCommit matches the prefix of conventional-commit messages, e.g. "feat(api)!: ..."
+var conventionalCommit = regexp.MustCompile(`^\s*([A-Za-z]+)\s*(\([^)]*\))?!?:`)
+
+// commitType returns the lowercase conventional-commit type of a commit message, or "unknown"
+func commitType(message string) string {
+	match := conventionalCommit.FindStringSubmatch(message)
+	if match == nil {
+		return "unknown"
+	}
+	return strings.ToLower(strings.TrimSpace(match[1]))
+}
+
+// summarizeCommits counts the commits committed during the window and their distinct authors
+func summarizeCommits(commits []CommitStruct, since time.Time, until time.Time) CommitSummary {
+	summary := CommitSummary{Authors: []string{}, Types: map[string]int{}}
+	authors := map[string]bool{}
+	for _, commit := range commits {
+		t, err := time.Parse(ISO_FORM, commit.CommittedDate)
+		if err != nil || !inWindow(t, since, until) {
+			continue
+		}
+		summary.Commits++
+		summary.Types[commitType(commit.Message)]++
+		if t.After(summary.LastCommitAt) {
+			summary.LastCommitAt = t
+		}
+		author := commit.Author.User.Login
+		if author == "" {
+			author = commit.Author.Name
+		}
+		if author != "" && !authors[author] {
+			authors[author] = true
+			summary.Authors = append(summary.Authors, author)
+		}
+	}
+	sort.Strings(summary.Authors)
+	return summary
+}
+
+// Run extracts the report from GitHub GraphQL API and stores it in gr.Result
+func (gr *ActivityReport) Run() error {
+	return gr.RunContext(context.Background())
+}
+
+// RunContext is like Run but uses ctx for every query sent to GitHub.
+// Canceling ctx aborts the scan and skips the remaining repositories.
+//
+// When the scan stops early (canceled context, exhausted rate limit, failing repository), the error
+// wraps ErrIncomplete and gr.Result holds the pull requests of the repositories already reported.
+func (gr *ActivityReport) RunContext(ctx context.Context) error {
+	result, err := gr.Generate(ctx)
+	if result != nil {
+		gr.ReportDate = result.ReportDate
+		gr.Result = *result
+		gr.TotalRepositories = result.TotalRepositories
+		gr.ScannedRepositories = result.ScannedRepositories
+	}
+	if err == nil && gr.Reporter != nil {
+		published := gr.exportResult()
+		if err := gr.Reporter.Publish(ctx, &published); err != nil {
+			return fmt.Errorf("An error occured during report publication: %w", err)
+		}
+	}
+	return err
+}
+
+// Generate extracts the report from GitHub GraphQL API and returns it.
+// Unlike Run, it leaves the ActivityReport untouched so several reports can be generated concurrently.
+//
+// When the scan stops early after listing the repositories, Generate returns the partial result
+// of the repositories already reported along with an *IncompleteError wrapping the cause.
+// With UseSearch, the partial result holds the pull requests of the searches already done.
+func (gr *ActivityReport) Generate(ctx context.Context) (*Result, error) {
+
+	if err := gr.Validate(); err != nil {
+		return nil, err
+	}
+
+	// create a client (safe to share across requests)
+	client := gr.newClient(ctx)
+
+	now := time.Now().In(gr.location())
+	since, until := gr.window(now)
+	return gr.generateWindow(ctx, client, now, since, until)
+}
+
+// generateWindow extracts the report of the window from since to until, dated now
+func (gr *ActivityReport) generateWindow(
+	ctx context.Context,
+	client *graphql.Client,
+	now time.Time,
+	since time.Time,
+	until time.Time) (*Result, error) {
+
+	result := &Result{ReportDate: now}
+
+	if gr.UseSearch {
+		err := gr.generateFromSearch(ctx, client, result, since, until)
+		result.Deduplicate()
+		gr.infof("Nb merged pr:%d\n", len(result.MergedPRs))
+		gr.infof("Nb open pr with activity:%d\n", len(result.OpenPRsWithActivity))
+		gr.infof("Nb open pr without activity:%d\n", len(result.OpenPRsWithoutActivity))
+		gr.infof("Nb closed pr:%d\n", len(result.ClosedPRs))
+		if err != nil {
+			return result, &IncompleteError{Err: err}
+		}
+		return result, nil
+	}
+
+	repositories, listing, err := gr.listOrganizationsRepositories(ctx, client, since)
+	if err != nil {
+		return nil, err
+	} else {
+		result.TotalRepositories = listing.Total
+		result.CachedListings = listing.CachedListings
+		result.SavedQueries = listing.SavedQueries
+		resumed, remaining := gr.resumeCheckpoint(repositories, since, until)
+		repoResults, repoErrors, err := gr.reportRepositories(ctx, client, remaining, since, until)
+		result.Errors = repoErrors
+		for _, repoResult := range append(resumed, repoResults...) {
+			if repoResult != nil {
+				result.add(repoResult)
+				result.ScannedRepositories++
+			}
+		}
+		result.Deduplicate()
+		gr.infof("Nb merged pr:%d\n", len(result.MergedPRs))
+		gr.infof("Nb open pr with activity:%d\n", len(result.OpenPRsWithActivity))
+		gr.infof("Nb open pr without activity:%d\n", len(result.OpenPRsWithoutActivity))
+		gr.infof("Nb closed pr:%d\n", len(result.ClosedPRs))
+		gr.infof("Nb active issues:%d\n", len(result.Issues))
+		if err != nil {
+			return result, &IncompleteError{Err: err}
+		}
+		gr.clearCheckpoints(repositories, since, until)
+		return result, nil
+	}
+}
+
+// ReportForRepository extracts the report of a single repository of Organization over the
+// configured window and returns it. Like Generate, it leaves the ActivityReport untouched.
+func (gr *ActivityReport) ReportForRepository(ctx context.Context, repo string) (*Result, error) {
+	if err := gr.Validate(); err != nil {
+		return nil, err
+	}
+	if repo == "" {
+		return nil, errors.New("repo must not be empty")
+	}
+
+	client := gr.newClient(ctx)
+
+	now := time.Now().In(gr.location())
+	since, until := gr.window(now)
+
+	repoResults, err := gr.processRepositories(ctx, client, []repositoryRef{{Organization: gr.Organization, Name: repo}}, since, until)
+	if err != nil {
+		return nil, err
+	}
+	result := &Result{ReportDate: now, TotalRepositories: 1, ScannedRepositories: 1}
+	result.add(repoResults[0])
+	result.Deduplicate()
+	return result, nil
+}
+
+// prKey identifies a pull request across organizations and repositories
+type prKey struct {
+	Org        string
+	Repository string
+	Number     int
+}
+
+func keyOf(pr PRStruct) prKey {
+	return prKey{pr.Org, pr.Repository, pr.Number}
+}
+
+// uniquePRs removes the pull requests already in seen, keeping the first occurrence, and adds the others to seen
+func uniquePRs(pullrequests []PRStruct, seen map[prKey]bool) []PRStruct {
+	if pullrequests == nil {
+		return nil
+	}
+	unique := []PRStruct{}
+	for _, pr := range pullrequests {
+		if seen[keyOf(pr)] {
+			continue
+		}
+		seen[keyOf(pr)] = true
+		unique = append(unique, pr)
+	}
+	return unique
+}
+
+// Deduplicate removes pull requests appearing several times, identified by organization,
+// repository and number. It is useful when assembling results from several runs.
+// An open pull request found both with and without activity is kept with activity.
+func (r *Result) Deduplicate() {
+	open := map[prKey]bool{}
+	r.OpenPRsWithActivity = uniquePRs(r.OpenPRsWithActivity, open)
+	r.OpenPRsWithoutActivity = uniquePRs(r.OpenPRsWithoutActivity, open)
+	r.MergedPRs = uniquePRs(r.MergedPRs, map[prKey]bool{})
+	r.ClosedPRs = uniquePRs(r.ClosedPRs, map[prKey]bool{})
+	r.DraftPRs = uniquePRs(r.DraftPRs, map[prKey]bool{})
+	r.StalePRs = uniquePRs(r.StalePRs, map[prKey]bool{})
+	r.ConflictingPRs = uniquePRs(r.ConflictingPRs, map[prKey]bool{})
+}
+
+// add appends the pull requests, issues and commit summaries of another result
+func (r *Result) add(other *Result) {
+	r.MergedPRs = append(r.MergedPRs, other.MergedPRs...)
+	r.OpenPRsWithActivity = append(r.OpenPRsWithActivity, other.OpenPRsWithActivity...)
+	r.OpenPRsWithoutActivity = append(r.OpenPRsWithoutActivity, other.OpenPRsWithoutActivity...)
+	r.ClosedPRs = append(r.ClosedPRs, other.ClosedPRs...)
+	r.DraftPRs = append(r.DraftPRs, other.DraftPRs...)
+	r.StalePRs = append(r.StalePRs, other.StalePRs...)
+	r.ConflictingPRs = append(r.ConflictingPRs, other.ConflictingPRs...)
+	r.Issues = append(r.Issues, other.Issues...)
+	for repoName, summary := range other.Commits {
+		if r.Commits == nil {
+			r.Commits = map[string]CommitSummary{}
+		}
+		r.Commits[repoName] = summary
+	}
+}
+
+// GroupByRepository splits the result by repository full name ("org/repo"), without querying
+// GitHub again. Every list of pull requests and issues is split, as well as the commit summaries.
+func (r *Result) GroupByRepository() map[string]*Result {
+	groups := map[string]*Result{}
+	group := func(repository string) *Result {
+		if groups[repository] == nil {
+			groups[repository] = &Result{ReportDate: r.ReportDate}
+		}
+		return groups[repository]
+	}
+	for _, pr := range r.MergedPRs {
+		g := group(fullName(pr.Org, pr.Repository))
+		g.MergedPRs = append(g.MergedPRs, pr)
+	}
+	for _, pr := range r.OpenPRsWithActivity {
+		g := group(fullName(pr.Org, pr.Repository))
+		g.OpenPRsWithActivity = append(g.OpenPRsWithActivity, pr)
+	}
+	for _, pr := range r.OpenPRsWithoutActivity {
+		g := group(fullName(pr.Org, pr.Repository))
+		g.OpenPRsWithoutActivity = append(g.OpenPRsWithoutActivity, pr)
+	}
+	for _, pr := range r.ClosedPRs {
+		g := group(fullName(pr.Org, pr.Repository))
+		g.ClosedPRs = append(g.ClosedPRs, pr)
+	}
+	for _, pr := range r.DraftPRs {
+		g := group(fullName(pr.Org, pr.Repository))
+		g.DraftPRs = append(g.DraftPRs, pr)
+	}
+	for _, pr := range r.StalePRs {
+		g := group(fullName(pr.Org, pr.Repository))
+		g.StalePRs = append(g.StalePRs, pr)
+	}
+	for _, pr := range r.ConflictingPRs {
+		g := group(fullName(pr.Org, pr.Repository))
+		g.ConflictingPRs = append(g.ConflictingPRs, pr)
+	}
+	for _, issue := range r.Issues {
+		g := group(fullName(issue.Org, issue.Repository))
+		g.Issues = append(g.Issues, issue)
+	}
+	for repository, summary := range r.Commits {
+		group(repository).Commits = map[string]CommitSummary{repository: summary}
+	}
+	return groups
+}
+
+// repositoryRef identifies a repository within an organization
+type repositoryRef struct {
+	Organization string
+	Name         string
+}
+
+// fullName returns the full name of a repository, "org/repo", or its name when the organization is unknown
+func fullName(organization string, repository string) string {
+	if organization == "" {
+		return repository
+	}
+	return organization + "/" + repository
+}
+
+// organizations returns the organizations covered by the report
+func (gr *ActivityReport) organizations() []string {
+	if len(gr.Organizations) > 0 {
+		return gr.Organizations
+	}
+	return []string{gr.Organization}
+}
+
+// listOrganizationsRepositories lists and filters the repositories of every organization of the report.
+// It also returns the total number of repositories of the organizations, before filtering.
+func (gr *ActivityReport) listOrganizationsRepositories(
+	ctx context.Context,
+	client *graphql.Client,
+	since time.Time) ([]repositoryRef, listingStats, error) {
+
+	refs := []repositoryRef{}
+	stats := listingStats{}
+	for _, organization := range gr.organizations() {
+		var repositories []RepositoryStruct
+		var err error
+		cached := false
+		count := -1
+		if gr.RepositoryCache != nil {
+			repositories, cached = gr.RepositoryCache.Get(gr.repositoryCacheKey(organization))
+		}
+		if cached {
+			gr.logf("Using cached repositories of %s\n", organization)
+			stats.CachedListings++
+			stats.SavedQueries += gr.listingQueries(len(repositories))
+		} else if gr.FullScan {
+			repositories, err = gr.listRepositories(ctx, client, organization, "")
+		} else {
+			repositories, count, err = gr.listSubsetRepositories(ctx, client, organization)
+		}
+		if err != nil {
+			return nil, stats, fmt.Errorf("An error occured during repositories listing of %s: %w", organization, err)
+		}
+		if count < 0 {
+			// Full and cached listings hold every repository
+			count = len(repositories)
+		}
+		stats.Total += count
+		if !cached && gr.RepositoryCache != nil {
+			gr.RepositoryCache.Set(gr.repositoryCacheKey(organization), repositories, gr.RepositoryCacheTTL)
+		}
+		for _, repo := range gr.filterRepositories(repositories, since) {
+			refs = append(refs, repositoryRef{Organization: organization, Name: repo.Name})
+		}
+	}
+	return refs, stats, nil
+}
+
+// listingStats counts the repositories listed by listOrganizationsRepositories, and the cache hits
+type listingStats struct {
+	Total          int
+	CachedListings int
+	SavedQueries   int
+}
+
+// listingQueries returns the number of queries needed to list count repositories
+func (gr *ActivityReport) listingQueries(count int) int {
+	size := gr.PageSize
+	if !gr.FullScan {
+		size = subsetPageSize
+	}
+	if count <= size {
+		return 1
+	}
+	return (count + size - 1) / size
+}
+
+// reportRepositories reports every repository using a pool of gr.Concurrency workers,
+// each one querying gr.BatchSize repositories at a time.
+// Results are returned in the order of repositories, whatever the concurrency level.
+// The first error cancels the remaining queries; the results of the repositories already
+// reported are still returned, nil for the others. Unless SkipFailedRepos is set: the failures
+// of individual repositories are then returned as *RepositoryError and their result is nil.
+func (gr *ActivityReport) reportRepositories(
+	parent context.Context,
+	client *graphql.Client,
+	repositories []repositoryRef,
+	since time.Time,
+	until time.Time) ([]*Result, []error, error) {
+
+	ctx, cancel := context.WithCancel(parent)
+	defer cancel()
+
+	workers := gr.Concurrency
+	if workers < 1 {
+		workers = 1
+	}
+	batchSize := gr.BatchSize
+	if batchSize < 1 {
+		batchSize = 1
+	}
+
+	results := make([]*Result, len(repositories))
+	repoErrors := make([]error, len(repositories))
+	var firstErr error
+	var errMu sync.Mutex
+
+	started := 0
+	var progressMu sync.Mutex
+	var reportMu sync.Mutex
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for start := range jobs {
+				end := start + batchSize
+				if end > len(repositories) {
+					end = len(repositories)
+				}
+				if gr.OnProgress != nil {
+					progressMu.Lock()
+					for i := start; i < end; i++ {
+						started++
+						gr.OnProgress(started, len(repositories), repositories[i].Name)
+					}
+					progressMu.Unlock()
+				}
+				batchResults, err := gr.processRepositories(ctx, client, repositories[start:end], since, until)
+				var repoErr *RepositoryError
+				if err != nil && gr.SkipFailedRepos && errors.As(err, &repoErr) && parent.Err() == nil {
+					// The failure of a batch fails each of its repositories
+					for i := start; i < end; i++ {
+						gr.warnf("Skipping %s/%s: %v\n", repositories[i].Organization, repositories[i].Name, repoErr.Err)
+						repoErrors[i] = &RepositoryError{Organization: repositories[i].Organization, Repository: repositories[i].Name, Err: repoErr.Err}
+					}
+					continue
+				}
+				if err != nil {
+					errMu.Lock()
+					if firstErr == nil {
+						firstErr = err
+						cancel()
+					}
+					errMu.Unlock()
+					continue
+				}
+				for k, repoResult := range batchResults {
+					results[start+k] = repoResult
+					if gr.Checkpoints != nil {
+						repo := repositories[start+k]
+						gr.Checkpoints.Save(gr.checkpointKey(repo.Organization, since, until), repo.Name, repoResult)
+					}
+					if gr.OnRepositoryReport != nil {
+						reportMu.Lock()
+						gr.OnRepositoryReport(repositories[start+k].Name, repoResult.MergedPRs, repoResult.OpenPRsWithActivity, repoResult.OpenPRsWithoutActivity)
+						reportMu.Unlock()
+					}
+				}
+			}
+		}()
+	}
+
+dispatch:
+	for i := 0; i < len(repositories); i += batchSize {
+		select {
+		case jobs <- i:
+		case <-ctx.Done():
+			break dispatch
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	var failures []error
+	for _, err := range repoErrors {
+		if err != nil {
+			failures = append(failures, err)
+		}
+	}
+	if firstErr != nil {
+		return results, failures, firstErr
+	}
+	return results, failures, parent.Err()
+}
+
+// processRepositories queries a batch of repositories and classifies their pull requests.
+// A single repository is queried on its own, several ones with one query using aliases.
+// When the query fails, the *RepositoryError returned names the first repository of the batch,
+// or the repository missing from the response of a batch.
+func (gr *ActivityReport) processRepositories(
+	ctx context.Context,
+	client *graphql.Client,
+	repositories []repositoryRef,
+	since time.Time,
+	until time.Time) ([]*Result, error) {
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if err := gr.checkRateLimit(ctx); err != nil {
+		return nil, err
+	}
+	var reports []repositoryReportStruct
+	if len(repositories) == 1 {
+		report, err := gr.reportRepository(ctx, client, repositories[0].Organization, repositories[0].Name, since)
+		if err != nil {
+			return nil, &RepositoryError{Organization: repositories[0].Organization, Repository: repositories[0].Name, Err: err}
+		}
+		reports = []repositoryReportStruct{report.Repository}
+	} else {
+		var err error
+		reports, err = gr.reportBatch(ctx, client, repositories, since)
+		var repoErr *RepositoryError
+		if errors.As(err, &repoErr) {
+			return nil, repoErr
+		}
+		if err != nil {
+			return nil, &RepositoryError{Organization: repositories[0].Organization, Repository: repositories[0].Name, Err: err}
+		}
+	}
+
+	results := make([]*Result, len(repositories))
+	for i, repo := range repositories {
+		results[i] = gr.classifyRepository(reports[i], repo, since, until)
+	}
+	return results, nil
+}
+
+// bucketOf returns the bucket of the pull requests in state, according to StateMapping
+func (gr *ActivityReport) bucketOf(state string) PRBucket {
+	mapping := gr.StateMapping
+	if mapping == nil {
+		mapping = DefaultStateMapping
+	}
+	bucket, ok := mapping[state]
+	if !ok {
+		return BucketIgnore
+	}
+	return bucket
+}
+
+// classifyPullRequests adds to result the merged, open and closed pull requests,
+// each state being classified in the bucket given by StateMapping
+func (gr *ActivityReport) classifyPullRequests(
+	result *Result,
+	merged []PRStruct,
+	open []PRStruct,
+	closed []PRStruct,
+	since time.Time,
+	until time.Time) {
+
+	for _, state := range []struct {
+		name         string
+		pullrequests []PRStruct
+	}{
+		{StateMerged, merged},
+		{StateOpen, open},
+		{StateClosed, closed},
+	} {
+		bucket := gr.bucketOf(state.name)
+		for _, pullrequest := range state.pullrequests {
+			if bucket == BucketIgnore || !gr.keepPullRequest(pullrequest) {
+				continue
+			}
+			pullrequest = gr.withoutBots(pullrequest)
+			switch bucket {
+			case BucketMerged:
+				// Keep the ones merged (or closed, for closed pull requests) during the report window
+				date := pullrequest.MergedAt
+				if date == "" {
+					date = pullrequest.ClosedAt
+				}
+				t, _ := time.Parse(ISO_FORM, date)
+				if inWindow(t, since, until) {
+					result.MergedPRs = append(result.MergedPRs, pullrequest)
+				}
+			case BucketOpen:
+				gr.classifyOpenPullRequest(result, pullrequest, since, until)
+			case BucketClosed:
+				// Keep the ones closed (or merged, for merged pull requests) during the report window
+				date := pullrequest.ClosedAt
+				if date == "" {
+					date = pullrequest.MergedAt
+				}
+				t, _ := time.Parse(ISO_FORM, date)
+				if inWindow(t, since, until) {
+					result.ClosedPRs = append(result.ClosedPRs, pullrequest)
+				}
+			}
+		}
+	}
+}
+
+// classifyOpenPullRequest adds an open pull request to the drafts or to the open with or without
+// activity, and to the stale and conflicting ones
+func (gr *ActivityReport) classifyOpenPullRequest(result *Result, pullrequest PRStruct, since time.Time, until time.Time) {
+	if gr.SeparateDrafts && pullrequest.IsDraft {
+		result.DraftPRs = append(result.DraftPRs, pullrequest)
+	} else if gr.isActive(pullrequest, since) {
+		result.OpenPRsWithActivity = append(result.OpenPRsWithActivity, pullrequest)
+	} else {
+		result.OpenPRsWithoutActivity = append(result.OpenPRsWithoutActivity, pullrequest)
+	}
+	if gr.isStale(pullrequest, since, until) {
+		result.StalePRs = append(result.StalePRs, pullrequest)
+	}
+	if pullrequest.Mergeable == "CONFLICTING" {
+		result.ConflictingPRs = append(result.ConflictingPRs, pullrequest)
+	}
+}
+
+// classifyRepository classifies the pull requests, issues and commits of a repository report
+func (gr *ActivityReport) classifyRepository(
+	report repositoryReportStruct,
+	repo repositoryRef,
+	since time.Time,
+	until time.Time) *Result {
+
+	result := &Result{}
+	gr.classifyPullRequests(result, report.MergedPR.Nodes, report.OpenPR.Nodes, report.ClosedPR.Nodes, since, until)
+
+	// Extract issues created, updated or closed during the report window
+	for _, issue := range report.Issues.Nodes {
+		if issueInWindow(issue, since, until) {
+			issue.Org = repo.Organization
+			issue.Repository = repo.Name
+			result.Issues = append(result.Issues, issue)
+		}
+	}
+
+	// Summarize the commits of the default branch, or of every branch counting each commit once
+	commits := []CommitStruct{}
+	if report.DefaultBranchRef != nil {
+		commits = append(commits, report.DefaultBranchRef.Target.History.Nodes...)
+	}
+	for _, ref := range report.Refs.Nodes {
+		commits = append(commits, ref.Target.History.Nodes...)
+	}
+	commits = uniqueCommits(commits)
+	result.Commits = map[string]CommitSummary{fullName(repo.Organization, repo.Name): summarizeCommits(commits, since, until)}
+	return result
 }
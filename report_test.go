@@ -0,0 +1,2005 @@
+package ghreport
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/oauth2"
+
+	"github.com/dsciamma/graphql"
+)
+
+// graphQLRequest is the body of a GraphQL query received by the test server
+type graphQLRequest struct {
+	Query     string                 `json:"query"`
+	Variables map[string]interface{} `json:"variables"`
+}
+
+// newGraphQLServer starts a test server answering each GraphQL query with handler.
+// The server is closed at the end of the test.
+func newGraphQLServer(t *testing.T, handler func(req graphQLRequest, w http.ResponseWriter)) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var req graphQLRequest
+		if err := json.Unmarshal(body, &req); err != nil {
+			t.Errorf("Invalid GraphQL request %q: %v", body, err)
+		}
+		handler(req, w)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// isListing reports whether req lists the repositories of an organization
+func isListing(req graphQLRequest) bool {
+	return strings.Contains(req.Query, "repositories(")
+}
+
+// listingJSON returns the response of a repository listing holding the given repositories
+func listingJSON(names ...string) string {
+	nodes := []string{}
+	for _, name := range names {
+		nodes = append(nodes, fmt.Sprintf(`{"name":%q}`, name))
+	}
+	return fmt.Sprintf(`{"data":{"organization":{"repositories":{"nodes":[%s],"totalCount":%d}}}}`,
+		strings.Join(nodes, ","), len(names))
+}
+
+// repositoryJSON returns the response of a repository report, fields being the JSON members
+// of the repository besides its name (e.g. `"mergedPR":{"nodes":[...]}`)
+func repositoryJSON(name string, fields string) string {
+	if fields == "" {
+		return fmt.Sprintf(`{"data":{"repository":{"name":%q}}}`, name)
+	}
+	return fmt.Sprintf(`{"data":{"repository":{"name":%q,%s}}}`, name, fields)
+}
+
+// newRepositoryServer starts a test server listing the given repositories,
+// and answering each repository report with fields (see repositoryJSON)
+func newRepositoryServer(t *testing.T, fields func(repo string) string, names ...string) *httptest.Server {
+	t.Helper()
+	return newGraphQLServer(t, func(req graphQLRequest, w http.ResponseWriter) {
+		if isListing(req) {
+			fmt.Fprint(w, listingJSON(names...))
+			return
+		}
+		repo, _ := req.Variables["repo"].(string)
+		fmt.Fprint(w, repositoryJSON(repo, fields(repo)))
+	})
+}
+
+// newTestReport returns a report of the organization acme over the last 7 days, querying url
+func newTestReport(url string) *ActivityReport {
+	report := NewActivityReport("acme", "token", 7)
+	report.BaseURL = url
+	report.RetryDelay = time.Millisecond
+	return report
+}
+
+// daysAgo returns the GitHub timestamp of days days ago
+func daysAgo(days int) string {
+	return time.Now().UTC().AddDate(0, 0, -days).Format(ISO_FORM)
+}
+
+func TestNewActivityReportDefaultsToPublicGitHub(t *testing.T) {
+	report := NewActivityReport("acme", "token", 7)
+	if report.BaseURL != DefaultBaseURL {
+		t.Fatalf("BaseURL = %q, want %q", report.BaseURL, DefaultBaseURL)
+	}
+}
+
+func TestRunQueriesBaseURL(t *testing.T) {
+	queried := false
+	server := newGraphQLServer(t, func(req graphQLRequest, w http.ResponseWriter) {
+		queried = true
+		fmt.Fprint(w, listingJSON())
+	})
+	report := newTestReport(server.URL + "/api/graphql")
+	if err := report.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if !queried {
+		t.Fatal("BaseURL was not queried")
+	}
+}
+
+func TestValidateRejectsInvalidBaseURL(t *testing.T) {
+	for _, baseURL := range []string{"", "github.mycorp.com/api/graphql", "ftp://github.mycorp.com", "http://"} {
+		report := NewActivityReport("acme", "token", 7)
+		report.BaseURL = baseURL
+		if err := report.Validate(); err == nil || !strings.Contains(err.Error(), "BaseURL") {
+			t.Errorf("Validate() with BaseURL %q = %v, want a BaseURL error", baseURL, err)
+		}
+	}
+}
+
+// newPagedListingServer starts a test server listing pages of repositories, one page per cursor:
+// the first page is returned without cursor, the following ones after the cursor "page<N>".
+// The listing queries received are appended to listings when it is not nil.
+func newPagedListingServer(t *testing.T, pages [][]string, listings *[]graphQLRequest) *httptest.Server {
+	t.Helper()
+	return newGraphQLServer(t, func(req graphQLRequest, w http.ResponseWriter) {
+		if !isListing(req) {
+			repo, _ := req.Variables["repo"].(string)
+			fmt.Fprint(w, repositoryJSON(repo, ""))
+			return
+		}
+		if listings != nil {
+			*listings = append(*listings, req)
+		}
+		page := 0
+		if cursor, ok := req.Variables["cursor"].(string); ok {
+			fmt.Sscanf(cursor, "page%d", &page)
+		}
+		nodes := []string{}
+		for _, name := range pages[page] {
+			nodes = append(nodes, fmt.Sprintf(`{"name":%q}`, name))
+		}
+		total := 0
+		for _, names := range pages {
+			total += len(names)
+		}
+		fmt.Fprintf(w, `{"data":{"organization":{"repositories":{"nodes":[%s],"pageInfo":{"hasNextPage":%t,"endCursor":"page%d"},"totalCount":%d}}}}`,
+			strings.Join(nodes, ","), page+1 < len(pages), page+1, total)
+	})
+}
+
+func TestFullScanListsEveryPage(t *testing.T) {
+	listings := []graphQLRequest{}
+	server := newPagedListingServer(t, [][]string{{"r1", "r2"}, {"r3"}, {"r4"}}, &listings)
+	report := newTestReport(server.URL)
+	if err := report.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if len(listings) != 3 {
+		t.Fatalf("%d listing pages requested, want 3", len(listings))
+	}
+}
+
+func TestSubsetScanListsTwoPagesOfTen(t *testing.T) {
+	listings := []graphQLRequest{}
+	server := newPagedListingServer(t, [][]string{{"r1"}, {"r2"}, {"r3"}}, &listings)
+	report := newTestReport(server.URL)
+	report.FullScan = false
+	if err := report.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if report.ScannedRepositories != 2 || report.TotalRepositories != 3 {
+		t.Fatalf("scanned %d of %d repositories, want 2 of 3", report.ScannedRepositories, report.TotalRepositories)
+	}
+	if len(listings) != 2 || listings[0].Variables["size"] != float64(subsetPageSize) || listings[1].Variables["size"] != float64(subsetPageSize) {
+		t.Fatalf("listing queries = %v, want 2 pages of %d", listings, subsetPageSize)
+	}
+}
+
+func TestSubsetScanPaginatesLikeFullScan(t *testing.T) {
+	for _, pages := range [][][]string{{{"r1"}}, {{"r1"}, {"r2"}}} {
+		listings := []graphQLRequest{}
+		server := newPagedListingServer(t, pages, &listings)
+		report := newTestReport(server.URL)
+		report.FullScan = false
+		if err := report.Run(); err != nil {
+			t.Fatal(err)
+		}
+		if len(listings) != len(pages) {
+			t.Fatalf("%d listing queries for %d pages", len(listings), len(pages))
+		}
+		if _, ok := listings[0].Variables["cursor"].(string); ok {
+			t.Errorf("first page queried with cursor %v", listings[0].Variables["cursor"])
+		}
+		if listings[0].Query != DefaultRepositoriesQuery {
+			t.Errorf("subset listing %q, want DefaultRepositoriesQuery", listings[0].Query)
+		}
+		if len(listings) > 1 && (listings[1].Variables["cursor"] != "page1" || listings[1].Query != listings[0].Query) {
+			t.Errorf("second page queried with %q and cursor %v, want the same query after page1", listings[1].Query, listings[1].Variables["cursor"])
+		}
+	}
+}
+
+func TestPageSizeIsSentAsQuerySize(t *testing.T) {
+	sizes := map[string]float64{}
+	var mu sync.Mutex
+	server := newGraphQLServer(t, func(req graphQLRequest, w http.ResponseWriter) {
+		mu.Lock()
+		defer mu.Unlock()
+		if isListing(req) {
+			sizes["listing"] = req.Variables["size"].(float64)
+			fmt.Fprint(w, listingJSON("api"))
+			return
+		}
+		sizes["repository"] = req.Variables["size"].(float64)
+		fmt.Fprint(w, repositoryJSON("api", ""))
+	})
+	report := newTestReport(server.URL)
+	report.PageSize = 25
+	if err := report.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if sizes["listing"] != 25 || sizes["repository"] != 25 {
+		t.Fatalf("query sizes = %v, want 25", sizes)
+	}
+}
+
+func TestValidateRejectsPageSizeOutOfRange(t *testing.T) {
+	for _, size := range []int{0, -1, MaxPageSize + 1} {
+		report := NewActivityReport("acme", "token", 7)
+		report.PageSize = size
+		if err := report.Validate(); err == nil || !strings.Contains(err.Error(), "PageSize") {
+			t.Errorf("Validate() with PageSize %d = %v, want a PageSize error", size, err)
+		}
+	}
+	report := NewActivityReport("acme", "token", 7)
+	if report.PageSize != DefaultPageSize {
+		t.Errorf("PageSize = %d, want %d", report.PageSize, DefaultPageSize)
+	}
+}
+
+func TestGenerateReturnsResultWithoutMutatingReport(t *testing.T) {
+	server := newRepositoryServer(t, func(repo string) string {
+		return fmt.Sprintf(`"mergedPR":{"nodes":[{"number":1,"title":"Fix","mergedAt":%q,"updatedAt":%q,
+			"labels":{"nodes":[{"name":"bug"}]},
+			"participants":{"nodes":[{"login":"alice"}]}}]}`, daysAgo(1), daysAgo(1))
+	}, "api")
+	report := newTestReport(server.URL)
+	result, err := report.Generate(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.MergedPRs) != 1 || result.MergedPRs[0].Repository != "api" {
+		t.Fatalf("MergedPRs = %+v", result.MergedPRs)
+	}
+	if result.ReportDate.IsZero() {
+		t.Error("ReportDate is not set")
+	}
+	if len(report.Result.MergedPRs) != 0 || !report.ReportDate.IsZero() {
+		t.Fatalf("Generate modified the report: %+v", report.Result)
+	}
+
+	// A second result does not share its slices with the first one
+	other, err := report.Generate(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	result.MergedPRs[0].Title = "changed"
+	result.MergedPRs[0].Participants.Nodes[0].Login = "mallory"
+	result.MergedPRs[0].Labels[0] = "changed"
+	result.MergedPRs = append(result.MergedPRs, PRStruct{Number: 2})
+	if len(other.MergedPRs) != 1 {
+		t.Fatalf("second MergedPRs = %+v", other.MergedPRs)
+	}
+	pr := other.MergedPRs[0]
+	if pr.Title != "Fix" || pr.Participants.Nodes[0].Login != "alice" || pr.Labels[0] != "bug" {
+		t.Errorf("mutating the first result changed the second one: %+v", pr)
+	}
+}
+
+func TestRunStoresResult(t *testing.T) {
+	server := newRepositoryServer(t, func(repo string) string {
+		return fmt.Sprintf(`"mergedPR":{"nodes":[{"number":1,"mergedAt":%q,"updatedAt":%q}]}`, daysAgo(1), daysAgo(1))
+	}, "api")
+	report := newTestReport(server.URL)
+	if err := report.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Result.MergedPRs) != 1 || report.ReportDate != report.Result.ReportDate {
+		t.Fatalf("Result = %+v", report.Result)
+	}
+}
+
+func TestRunContextStopsWhenCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	var mu sync.Mutex
+	requested := []string{}
+	server := newGraphQLServer(t, func(req graphQLRequest, w http.ResponseWriter) {
+		if isListing(req) {
+			fmt.Fprint(w, listingJSON("api", "web"))
+			return
+		}
+		repo, _ := req.Variables["repo"].(string)
+		mu.Lock()
+		requested = append(requested, repo)
+		mu.Unlock()
+		// The run is canceled while the first repository is reported
+		cancel()
+		time.Sleep(50 * time.Millisecond)
+		fmt.Fprint(w, repositoryJSON(repo, ""))
+	})
+	report := newTestReport(server.URL)
+	report.MaxRetries = 0
+	report.Concurrency = 1
+	err := report.RunContext(ctx)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("RunContext() = %v, want context.Canceled", err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if fmt.Sprint(requested) != "[api]" {
+		t.Fatalf("requested %v, want only api: web must not be reported after the cancellation", requested)
+	}
+}
+
+func TestRunContextWithCanceledContext(t *testing.T) {
+	queried := false
+	server := newGraphQLServer(t, func(req graphQLRequest, w http.ResponseWriter) {
+		queried = true
+		fmt.Fprint(w, listingJSON())
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	report := newTestReport(server.URL)
+	if err := report.RunContext(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("RunContext() = %v, want context.Canceled", err)
+	}
+	if queried {
+		t.Error("GitHub was queried with a canceled context")
+	}
+}
+
+func TestConcurrencyBoundsParallelQueriesAndKeepsOrder(t *testing.T) {
+	names := []string{"r1", "r2", "r3", "r4", "r5", "r6", "r7", "r8"}
+	var running, maxRunning int32
+	server := newGraphQLServer(t, func(req graphQLRequest, w http.ResponseWriter) {
+		if isListing(req) {
+			fmt.Fprint(w, listingJSON(names...))
+			return
+		}
+		current := atomic.AddInt32(&running, 1)
+		for {
+			max := atomic.LoadInt32(&maxRunning)
+			if current <= max || atomic.CompareAndSwapInt32(&maxRunning, max, current) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		atomic.AddInt32(&running, -1)
+		repo := req.Variables["repo"].(string)
+		fmt.Fprint(w, repositoryJSON(repo, `"openPR":{"nodes":[{"number":1,"activity":{"totalCount":1}}]}`))
+	})
+	for _, concurrency := range []int{1, 3} {
+		atomic.StoreInt32(&maxRunning, 0)
+		report := newTestReport(server.URL)
+		report.Concurrency = concurrency
+		if err := report.Run(); err != nil {
+			t.Fatal(err)
+		}
+		if maxRunning > int32(concurrency) {
+			t.Errorf("Concurrency %d: %d repositories queried in parallel", concurrency, maxRunning)
+		}
+		if concurrency > 1 && maxRunning < 2 {
+			t.Errorf("Concurrency %d: repositories were queried one at a time", concurrency)
+		}
+		open := report.Result.OpenPRsWithActivity
+		if len(open) != len(names) {
+			t.Fatalf("Concurrency %d: %d open pull requests, want %d", concurrency, len(open), len(names))
+		}
+		for i, pr := range open {
+			if pr.Repository != names[i] {
+				t.Fatalf("Concurrency %d: pull request %d from %s, want %s", concurrency, i, pr.Repository, names[i])
+			}
+		}
+	}
+}
+
+func TestRunReportsClosedPullRequestsInWindow(t *testing.T) {
+	server := newRepositoryServer(t, func(repo string) string {
+		return fmt.Sprintf(`"closedPR":{"nodes":[
+			{"number":1,"title":"Abandoned","state":"CLOSED","closedAt":%q},
+			{"number":2,"title":"Old","state":"CLOSED","closedAt":%q}]}`, daysAgo(2), daysAgo(30))
+	}, "api")
+	report := newTestReport(server.URL)
+	if err := report.Run(); err != nil {
+		t.Fatal(err)
+	}
+	closed := report.Result.ClosedPRs
+	if len(closed) != 1 || closed[0].Number != 1 || closed[0].Repository != "api" {
+		t.Fatalf("ClosedPRs = %+v, want only #1 of api", closed)
+	}
+	if len(report.Result.MergedPRs) != 0 {
+		t.Errorf("MergedPRs = %+v, want none", report.Result.MergedPRs)
+	}
+}
+
+func TestRunUsesExplicitWindow(t *testing.T) {
+	var dates []interface{}
+	var mu sync.Mutex
+	server := newGraphQLServer(t, func(req graphQLRequest, w http.ResponseWriter) {
+		if isListing(req) {
+			fmt.Fprint(w, listingJSON("api"))
+			return
+		}
+		mu.Lock()
+		dates = append(dates, req.Variables["date"], req.Variables["date2"])
+		mu.Unlock()
+		fmt.Fprint(w, repositoryJSON("api", `"mergedPR":{"nodes":[
+			{"number":1,"title":"Before","mergedAt":"2020-02-29T12:00:00Z"},
+			{"number":2,"title":"Sprint","mergedAt":"2020-03-05T12:00:00Z"},
+			{"number":3,"title":"After","mergedAt":"2020-03-15T12:00:00Z"}]}`))
+	})
+	report := newTestReport(server.URL)
+	report.StartDate = time.Date(2020, 3, 1, 0, 0, 0, 0, time.UTC)
+	report.EndDate = time.Date(2020, 3, 14, 0, 0, 0, 0, time.UTC)
+	if err := report.Run(); err != nil {
+		t.Fatal(err)
+	}
+	merged := report.Result.MergedPRs
+	if len(merged) != 1 || merged[0].Number != 2 {
+		t.Fatalf("MergedPRs = %+v, want only #2", merged)
+	}
+	for _, date := range dates {
+		if date != "2020-03-01T00:00:00Z" {
+			t.Errorf("date variable = %v, want the start of the window", date)
+		}
+	}
+}
+
+func TestValidateRejectsEndDateBeforeStartDate(t *testing.T) {
+	report := NewActivityReport("acme", "token", 7)
+	report.StartDate = time.Date(2020, 3, 14, 0, 0, 0, 0, time.UTC)
+	report.EndDate = time.Date(2020, 3, 1, 0, 0, 0, 0, time.UTC)
+	if err := report.Validate(); err == nil {
+		t.Fatal("Validate() accepted an EndDate before StartDate")
+	}
+}
+
+func TestRunSetsRepositoryOnEveryPullRequest(t *testing.T) {
+	server := newRepositoryServer(t, func(repo string) string {
+		return fmt.Sprintf(`"mergedPR":{"nodes":[{"number":1,"mergedAt":%q}]},
+			"openPR":{"nodes":[
+				{"number":2,"state":"OPEN","createdAt":%q,"activity":{"totalCount":3}},
+				{"number":3,"state":"OPEN","createdAt":%q,"activity":{"totalCount":0}}]},
+			"closedPR":{"nodes":[{"number":4,"state":"CLOSED","closedAt":%q}]}`,
+			daysAgo(1), daysAgo(20), daysAgo(20), daysAgo(1))
+	}, "api", "web")
+	report := newTestReport(server.URL)
+	if err := report.Run(); err != nil {
+		t.Fatal(err)
+	}
+	for name, list := range map[string][]PRStruct{
+		"MergedPRs":              report.Result.MergedPRs,
+		"OpenPRsWithActivity":    report.Result.OpenPRsWithActivity,
+		"OpenPRsWithoutActivity": report.Result.OpenPRsWithoutActivity,
+		"ClosedPRs":              report.Result.ClosedPRs,
+	} {
+		if len(list) != 2 {
+			t.Errorf("%s = %+v, want one pull request per repository", name, list)
+		}
+		for _, pr := range list {
+			if pr.Repository != "api" && pr.Repository != "web" {
+				t.Errorf("%s: #%d has Repository %q", name, pr.Number, pr.Repository)
+			}
+		}
+	}
+}
+
+func TestRunAggregatesOrganizations(t *testing.T) {
+	server := newGraphQLServer(t, func(req graphQLRequest, w http.ResponseWriter) {
+		org, _ := req.Variables["organization"].(string)
+		if isListing(req) {
+			if org == "acme" {
+				fmt.Fprint(w, listingJSON("api", "web"))
+			} else {
+				fmt.Fprint(w, listingJSON("api"))
+			}
+			return
+		}
+		repo, _ := req.Variables["repo"].(string)
+		fmt.Fprint(w, repositoryJSON(repo, fmt.Sprintf(`"mergedPR":{"nodes":[{"number":1,"title":%q,"mergedAt":%q}]}`,
+			org+"/"+repo, daysAgo(1))))
+	})
+	report := NewMultiOrganizationReport([]string{"acme", "globex"}, "token", 7)
+	report.BaseURL = server.URL
+	if err := report.Run(); err != nil {
+		t.Fatal(err)
+	}
+	titles := map[string]bool{}
+	for _, pr := range report.Result.MergedPRs {
+		if pr.Title != pr.Org+"/"+pr.Repository {
+			t.Errorf("#%d %q tagged with %s/%s", pr.Number, pr.Title, pr.Org, pr.Repository)
+		}
+		titles[pr.Title] = true
+	}
+	if len(titles) != 3 || !titles["acme/api"] || !titles["acme/web"] || !titles["globex/api"] {
+		t.Fatalf("MergedPRs = %+v, want one per repository of both organizations", report.Result.MergedPRs)
+	}
+}
+
+func TestRunDecodesReviews(t *testing.T) {
+	server := newRepositoryServer(t, func(repo string) string {
+		return fmt.Sprintf(`"openPR":{"nodes":[
+			{"number":1,"state":"OPEN","createdAt":%[1]q,"reviews":{"totalCount":2},"reviewDecision":"APPROVED"},
+			{"number":2,"state":"OPEN","createdAt":%[1]q,"reviews":{"totalCount":0},"reviewDecision":null}]}`, daysAgo(20))
+	}, "api")
+	report := newTestReport(server.URL)
+	if err := report.Run(); err != nil {
+		t.Fatal(err)
+	}
+	open := report.Result.OpenPRsWithoutActivity
+	if len(open) != 2 {
+		t.Fatalf("OpenPRsWithoutActivity = %+v, want 2 pull requests", open)
+	}
+	if open[0].ReviewCount() != 2 || open[0].ReviewDecision != "APPROVED" {
+		t.Errorf("#1 has %d reviews and decision %q, want 2 and APPROVED", open[0].ReviewCount(), open[0].ReviewDecision)
+	}
+	if open[1].ReviewCount() != 0 || open[1].ReviewDecision != "" {
+		t.Errorf("#2 has %d reviews and decision %q, want 0 and none", open[1].ReviewCount(), open[1].ReviewDecision)
+	}
+}
+
+// newFailingRepositoryServer starts a test server listing api, broken and web, where the query of broken fails
+func newFailingRepositoryServer(t *testing.T) *httptest.Server {
+	return newGraphQLServer(t, func(req graphQLRequest, w http.ResponseWriter) {
+		if isListing(req) {
+			fmt.Fprint(w, listingJSON("api", "broken", "web"))
+			return
+		}
+		repo, _ := req.Variables["repo"].(string)
+		if repo == "broken" {
+			fmt.Fprint(w, `{"data":{"repository":null},"errors":[{"message":"Could not resolve to a Repository with the name 'acme/broken'."}]}`)
+			return
+		}
+		fmt.Fprint(w, repositoryJSON(repo, fmt.Sprintf(`"mergedPR":{"nodes":[{"number":1,"mergedAt":%q}]}`, daysAgo(1))))
+	})
+}
+
+func TestRunSkipsFailedRepositories(t *testing.T) {
+	report := newTestReport(newFailingRepositoryServer(t).URL)
+	report.SkipFailedRepos = true
+	if err := report.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Result.MergedPRs) != 2 {
+		t.Fatalf("MergedPRs = %+v, want the pull requests of api and web", report.Result.MergedPRs)
+	}
+	if len(report.Result.Errors) != 1 {
+		t.Fatalf("Errors = %v, want 1 error", report.Result.Errors)
+	}
+	var repoErr *RepositoryError
+	if !errors.As(report.Result.Errors[0], &repoErr) || repoErr.Organization != "acme" || repoErr.Repository != "broken" {
+		t.Fatalf("Errors[0] = %#v, want a *RepositoryError for acme/broken", report.Result.Errors[0])
+	}
+}
+
+func TestRunFailsOnRepositoryErrorByDefault(t *testing.T) {
+	report := newTestReport(newFailingRepositoryServer(t).URL)
+	err := report.Run()
+	var repoErr *RepositoryError
+	if !errors.As(err, &repoErr) || repoErr.Repository != "broken" {
+		t.Fatalf("Run() = %v, want a *RepositoryError for broken", err)
+	}
+}
+
+// newHeaderServer starts a test server listing the repository api, and returning the values
+// of the header name received with each query
+func newHeaderServer(t *testing.T, name string) (*httptest.Server, func() []string) {
+	t.Helper()
+	var mu sync.Mutex
+	values := []string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		values = append(values, r.Header.Get(name))
+		mu.Unlock()
+		body, _ := io.ReadAll(r.Body)
+		if strings.Contains(string(body), "repositories(") {
+			fmt.Fprint(w, listingJSON("api"))
+		} else {
+			fmt.Fprint(w, repositoryJSON("api", ""))
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string{}, values...)
+	}
+}
+
+// staticTokenSource returns a fixed token and counts the calls to Token
+type staticTokenSource struct {
+	token string
+	calls int32
+}
+
+func (ts *staticTokenSource) Token() (*oauth2.Token, error) {
+	atomic.AddInt32(&ts.calls, 1)
+	return &oauth2.Token{AccessToken: ts.token}, nil
+}
+
+func TestRunAuthenticatesWithTokenSource(t *testing.T) {
+	server, authorizations := newHeaderServer(t, "Authorization")
+	ts := &staticTokenSource{token: "installation-token"}
+	report := NewActivityReportWithTokenSource("acme", ts, 7)
+	report.BaseURL = server.URL
+	if err := report.Run(); err != nil {
+		t.Fatal(err)
+	}
+	for _, authorization := range authorizations() {
+		if authorization != "Bearer installation-token" {
+			t.Errorf("Authorization = %q, want the token of the token source", authorization)
+		}
+	}
+	if atomic.LoadInt32(&ts.calls) == 0 {
+		t.Fatal("the token source was never called")
+	}
+}
+
+func TestRunUsesHTTPClient(t *testing.T) {
+	server, authorizations := newHeaderServer(t, "Authorization")
+	report := NewActivityReport("acme", "ignored", 7)
+	report.BaseURL = server.URL
+	report.HTTPClient = &http.Client{
+		Transport: &oauth2.Transport{Source: oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "app-token"})},
+	}
+	if err := report.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if got := authorizations(); len(got) != 2 || got[0] != "Bearer app-token" {
+		t.Fatalf("Authorization headers = %v, want the token of the HTTP client", got)
+	}
+}
+
+func TestRunPaginatesParticipants(t *testing.T) {
+	var cursors []interface{}
+	server := newGraphQLServer(t, func(req graphQLRequest, w http.ResponseWriter) {
+		switch {
+		case isListing(req):
+			fmt.Fprint(w, listingJSON("api"))
+		case strings.Contains(req.Query, "pullRequest(number: $number)"):
+			cursors = append(cursors, req.Variables["cursor"])
+			if req.Variables["cursor"] == "p1" {
+				fmt.Fprint(w, `{"data":{"repository":{"pullRequest":{"participants":{"nodes":[{"login":"carol"}],"pageInfo":{"hasNextPage":true,"endCursor":"p2"},"totalCount":4}}}}}`)
+			} else {
+				fmt.Fprint(w, `{"data":{"repository":{"pullRequest":{"participants":{"nodes":[{"login":"dave"}],"pageInfo":{"hasNextPage":false,"endCursor":"p3"},"totalCount":4}}}}}`)
+			}
+		default:
+			fmt.Fprint(w, repositoryJSON("api", fmt.Sprintf(`"mergedPR":{"nodes":[{"number":7,"mergedAt":%q,
+				"participants":{"nodes":[{"login":"alice"},{"login":"bob"}],"pageInfo":{"hasNextPage":true,"endCursor":"p1"},"totalCount":4}}]}`, daysAgo(1))))
+		}
+	})
+	report := newTestReport(server.URL)
+	report.PageSize = 2
+	if err := report.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Result.MergedPRs) != 1 {
+		t.Fatalf("MergedPRs = %+v", report.Result.MergedPRs)
+	}
+	if logins := participantLogins(report.Result.MergedPRs[0]); logins != "alice, bob, carol, dave" {
+		t.Errorf("participants = %q, want alice, bob, carol, dave", logins)
+	}
+	if fmt.Sprint(cursors) != "[p1 p2]" {
+		t.Errorf("cursors = %v, want [p1 p2]", cursors)
+	}
+}
+
+// commitJSON returns a commit of the history of a branch
+func commitJSON(oid string, login string, date string, message string) string {
+	return fmt.Sprintf(`{"oid":%q,"committedDate":%q,"author":{"name":%q,"user":{"login":%q}},"message":%q}`,
+		oid, date, login, login, message)
+}
+
+func TestRunSummarizesCommitsByRepository(t *testing.T) {
+	server := newGraphQLServer(t, func(req graphQLRequest, w http.ResponseWriter) {
+		org, _ := req.Variables["organization"].(string)
+		if isListing(req) {
+			fmt.Fprint(w, listingJSON("api"))
+			return
+		}
+		shared := commitJSON(org+"1", "alice", daysAgo(1), "feat: add "+org)
+		main := fmt.Sprintf(`{"name":"main","target":{"history":{"nodes":[%s,%s]}}}`,
+			shared, commitJSON(org+"2", "bob", daysAgo(2), "fix(api): typo"))
+		topic := fmt.Sprintf(`{"name":"topic","target":{"history":{"nodes":[%s,%s,%s]}}}`,
+			shared, commitJSON(org+"3", "alice", daysAgo(3), "Update README"), commitJSON(org+"4", "carol", daysAgo(30), "chore: old"))
+		fmt.Fprint(w, repositoryJSON("api", fmt.Sprintf(`"refs":{"nodes":[%s,%s]}`, main, topic)))
+	})
+	report := NewMultiOrganizationReport([]string{"acme", "globex"}, "token", 7)
+	report.BaseURL = server.URL
+	if err := report.Run(); err != nil {
+		t.Fatal(err)
+	}
+	commits := report.Result.Commits
+	if len(commits) != 2 {
+		t.Fatalf("Commits = %+v, want acme/api and globex/api", commits)
+	}
+	for _, repository := range []string{"acme/api", "globex/api"} {
+		summary := commits[repository]
+		if summary.Commits != 3 || len(summary.Authors) != 2 {
+			t.Errorf("Commits[%s] = %+v, want 3 commits by 2 authors", repository, summary)
+		}
+	}
+	breakdown := report.Result.CommitTypeBreakdown()
+	if breakdown["feat"] != 2 || breakdown["fix"] != 2 || breakdown["unknown"] != 2 || len(breakdown) != 3 {
+		t.Fatalf("CommitTypeBreakdown() = %v, want 2 feat, 2 fix and 2 unknown", breakdown)
+	}
+	groups := report.Result.GroupByRepository()
+	if len(groups) != 2 || groups["acme/api"].Commits["acme/api"].Commits != 3 {
+		t.Fatalf("GroupByRepository() = %v, want acme/api and globex/api", groups)
+	}
+}
+
+func TestRunSeparatesDrafts(t *testing.T) {
+	server := newRepositoryServer(t, func(repo string) string {
+		return fmt.Sprintf(`"openPR":{"nodes":[
+			{"number":1,"state":"OPEN","isDraft":true,"createdAt":%[1]q,"activity":{"totalCount":1}},
+			{"number":2,"state":"OPEN","isDraft":false,"createdAt":%[1]q,"activity":{"totalCount":1}}]}`, daysAgo(3))
+	}, "api")
+	for _, separate := range []bool{false, true} {
+		report := newTestReport(server.URL)
+		report.SeparateDrafts = separate
+		if err := report.Run(); err != nil {
+			t.Fatal(err)
+		}
+		active, drafts := report.Result.OpenPRsWithActivity, report.Result.DraftPRs
+		if !separate && (len(active) != 2 || len(drafts) != 0 || !active[0].IsDraft) {
+			t.Errorf("without SeparateDrafts: active %+v, drafts %+v", active, drafts)
+		}
+		if separate && (len(active) != 1 || active[0].Number != 2 || len(drafts) != 1 || drafts[0].Number != 1) {
+			t.Errorf("with SeparateDrafts: active %+v, drafts %+v", active, drafts)
+		}
+	}
+}
+
+func TestRunSummarizesDefaultBranchOnly(t *testing.T) {
+	var flags []interface{}
+	server := newGraphQLServer(t, func(req graphQLRequest, w http.ResponseWriter) {
+		if isListing(req) {
+			fmt.Fprint(w, listingJSON("api"))
+			return
+		}
+		flags = append(flags, req.Variables["defaultBranchOnly"])
+		fmt.Fprint(w, repositoryJSON("api", fmt.Sprintf(`"defaultBranchRef":{"name":"main","target":{"history":{"nodes":[%s,%s]}}}`,
+			commitJSON("a1", "alice", daysAgo(1), "feat: one"), commitJSON("a2", "alice", daysAgo(2), "feat: two"))))
+	})
+	report := newTestReport(server.URL)
+	report.DefaultBranchOnly = true
+	if err := report.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(flags) != "[true]" {
+		t.Errorf("defaultBranchOnly variables = %v, want [true]", flags)
+	}
+	if summary := report.Result.Commits["acme/api"]; summary.Commits != 2 || len(summary.Authors) != 1 {
+		t.Fatalf("Commits[acme/api] = %+v, want 2 commits by 1 author", summary)
+	}
+}
+
+func TestUniqueCommitsCountsEachOidOnce(t *testing.T) {
+	commits := uniqueCommits([]CommitStruct{{Oid: "a"}, {Oid: "b"}, {Oid: "a"}, {Oid: "c"}, {Oid: "b"}})
+	oids := []string{}
+	for _, commit := range commits {
+		oids = append(oids, commit.Oid)
+	}
+	if strings.Join(oids, ",") != "a,b,c" {
+		t.Fatalf("uniqueCommits() = %v, want [a b c]", oids)
+	}
+}
+
+func TestRunCallsOnProgressBeforeEachRepository(t *testing.T) {
+	var mu sync.Mutex
+	var reported []string
+	server := newRepositoryServer(t, func(repo string) string {
+		mu.Lock()
+		reported = append(reported, repo)
+		mu.Unlock()
+		return ""
+	}, "api", "web", "docs")
+	report := newTestReport(server.URL)
+	var progress []string
+	report.OnProgress = func(done, total int, repo string) {
+		mu.Lock()
+		defer mu.Unlock()
+		if len(reported) >= done {
+			t.Errorf("OnProgress(%d, %d, %s) called after the repository was reported", done, total, repo)
+		}
+		progress = append(progress, fmt.Sprintf("%d/%d", done, total))
+	}
+	if err := report.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(progress, " ") != "1/3 2/3 3/3" {
+		t.Fatalf("progress = %v, want 1/3 2/3 3/3", progress)
+	}
+}
+
+func TestRunReportsIssuesInWindow(t *testing.T) {
+	var cursors []interface{}
+	server := newGraphQLServer(t, func(req graphQLRequest, w http.ResponseWriter) {
+		switch {
+		case isListing(req):
+			fmt.Fprint(w, listingJSON("api"))
+		case strings.Contains(req.Query, "after: $cursor"):
+			cursors = append(cursors, req.Variables["cursor"])
+			fmt.Fprint(w, `{"data":{"repository":{"issues":{"nodes":[
+				{"number":4,"title":"Updated in window","createdAt":"2019-12-01T00:00:00Z","updatedAt":"2020-03-10T00:00:00Z"},
+				{"number":5,"title":"Updated before","createdAt":"2019-12-01T00:00:00Z","updatedAt":"2020-02-20T00:00:00Z"}],
+				"pageInfo":{"hasNextPage":false,"endCursor":"i2"}}}}}`)
+		default:
+			fmt.Fprint(w, repositoryJSON("api", `"issues":{"nodes":[
+				{"number":1,"title":"Updated after","createdAt":"2019-12-01T00:00:00Z","updatedAt":"2020-04-01T00:00:00Z"},
+				{"number":2,"title":"Created in window","createdAt":"2020-03-02T00:00:00Z","updatedAt":"2020-04-01T00:00:00Z"},
+				{"number":3,"title":"Closed in window","createdAt":"2019-12-01T00:00:00Z","updatedAt":"2020-03-20T00:00:00Z","closedAt":"2020-03-12T00:00:00Z"}],
+				"pageInfo":{"hasNextPage":true,"endCursor":"i1"}}`))
+		}
+	})
+	report := newTestReport(server.URL)
+	report.StartDate = time.Date(2020, 3, 1, 0, 0, 0, 0, time.UTC)
+	report.EndDate = time.Date(2020, 3, 14, 0, 0, 0, 0, time.UTC)
+	if err := report.Run(); err != nil {
+		t.Fatal(err)
+	}
+	numbers := []int{}
+	for _, issue := range report.Result.Issues {
+		numbers = append(numbers, issue.Number)
+		if issue.Org != "acme" || issue.Repository != "api" {
+			t.Errorf("issue #%d of %s/%s", issue.Number, issue.Org, issue.Repository)
+		}
+	}
+	if fmt.Sprint(numbers) != "[2 3 4]" {
+		t.Fatalf("Issues = %v, want [2 3 4]", numbers)
+	}
+	if fmt.Sprint(cursors) != "[i1]" {
+		t.Fatalf("cursors = %v, want [i1]", cursors)
+	}
+}
+
+func TestListingSendsAffiliationsOnEveryPage(t *testing.T) {
+	listings := []graphQLRequest{}
+	server := newPagedListingServer(t, [][]string{{"r1"}, {"r2"}}, &listings)
+	for _, test := range []struct {
+		affiliations []string
+		want         string
+	}{
+		{nil, "[OWNER]"},
+		{[]string{AffiliationOwner, AffiliationCollaborator}, "[OWNER COLLABORATOR]"},
+	} {
+		listings = listings[:0]
+		report := newTestReport(server.URL)
+		report.Affiliations = test.affiliations
+		if err := report.Run(); err != nil {
+			t.Fatal(err)
+		}
+		if len(listings) != 2 {
+			t.Fatalf("%d listing queries, want 2 pages", len(listings))
+		}
+		for i, listing := range listings {
+			if got := fmt.Sprint(listing.Variables["affiliations"]); got != test.want {
+				t.Errorf("page %d: affiliations = %s, want %s", i+1, got, test.want)
+			}
+		}
+	}
+}
+
+func TestListingPagesShareTheSameQuery(t *testing.T) {
+	listings := []graphQLRequest{}
+	server := newPagedListingServer(t, [][]string{{"r1"}, {"r2"}}, &listings)
+	report := newTestReport(server.URL)
+	if err := report.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if len(listings) != 2 {
+		t.Fatalf("%d listing queries, want 2 pages", len(listings))
+	}
+	if listings[0].Query != listings[1].Query {
+		t.Fatalf("the pages were listed with different queries:\n%s\n%s", listings[0].Query, listings[1].Query)
+	}
+	if cursor, ok := listings[0].Variables["cursor"]; ok && cursor != nil {
+		t.Errorf("first page sent with cursor %v", cursor)
+	}
+	if listings[1].Variables["cursor"] != "page1" {
+		t.Errorf("second page sent with cursor %v, want page1", listings[1].Variables["cursor"])
+	}
+}
+
+func TestSummary(t *testing.T) {
+	report := NewActivityReport("acme", "token", 7)
+	if got, want := report.Summary(), "Org acme | window 7d | merged 0, open+activity 0, open+idle 0"; got != want {
+		t.Errorf("Summary() before Run = %q, want %q", got, want)
+	}
+	report.Result.MergedPRs = make([]PRStruct, 12)
+	report.Result.OpenPRsWithActivity = make([]PRStruct, 5)
+	report.Result.OpenPRsWithoutActivity = make([]PRStruct, 9)
+	if got, want := report.Summary(), "Org acme | window 7d | merged 12, open+activity 5, open+idle 9"; got != want {
+		t.Errorf("Summary() = %q, want %q", got, want)
+	}
+	report.StartDate = time.Date(2020, 3, 1, 0, 0, 0, 0, time.UTC)
+	report.EndDate = time.Date(2020, 3, 14, 0, 0, 0, 0, time.UTC)
+	if got, want := report.Summary(), "Org acme | window 2020-03-01..2020-03-14 | merged 12, open+activity 5, open+idle 9"; got != want {
+		t.Errorf("Summary() with StartDate = %q, want %q", got, want)
+	}
+}
+
+func TestRunClassifiesActivityWithThreshold(t *testing.T) {
+	server := newRepositoryServer(t, func(repo string) string {
+		return fmt.Sprintf(`"openPR":{"nodes":[
+			{"number":1,"state":"OPEN","createdAt":%[1]q,"activity":{"totalCount":2}},
+			{"number":2,"state":"OPEN","createdAt":%[1]q,"activity":{"totalCount":3}}]}`, daysAgo(20))
+	}, "api")
+	report := newTestReport(server.URL)
+	report.ActivityThreshold = 3
+	if err := report.Run(); err != nil {
+		t.Fatal(err)
+	}
+	active, idle := report.Result.OpenPRsWithActivity, report.Result.OpenPRsWithoutActivity
+	if len(active) != 1 || active[0].Number != 2 || len(idle) != 1 || idle[0].Number != 1 {
+		t.Fatalf("active %+v, idle %+v: want #2 active and #1 idle", active, idle)
+	}
+}
+
+func TestRunContextKeepsPartialResultWhenCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	server := newGraphQLServer(t, func(req graphQLRequest, w http.ResponseWriter) {
+		if isListing(req) {
+			fmt.Fprint(w, listingJSON("api", "web"))
+			return
+		}
+		repo, _ := req.Variables["repo"].(string)
+		if repo == "web" {
+			cancel()
+			time.Sleep(50 * time.Millisecond)
+		}
+		fmt.Fprint(w, repositoryJSON(repo, fmt.Sprintf(`"mergedPR":{"nodes":[{"number":1,"mergedAt":%q}]}`, daysAgo(1))))
+	})
+	report := newTestReport(server.URL)
+	report.Concurrency = 1
+	err := report.RunContext(ctx)
+	var incomplete *IncompleteError
+	if !errors.Is(err, ErrIncomplete) || !errors.As(err, &incomplete) || !errors.Is(err, context.Canceled) {
+		t.Fatalf("RunContext() = %v, want an *IncompleteError caused by context.Canceled", err)
+	}
+	merged := report.Result.MergedPRs
+	if len(merged) != 1 || merged[0].Repository != "api" {
+		t.Fatalf("MergedPRs = %+v, want the pull request of api", merged)
+	}
+}
+
+func TestDeduplicate(t *testing.T) {
+	r := &Result{
+		MergedPRs: []PRStruct{
+			{Org: "acme", Repository: "api", Number: 1},
+			{Org: "acme", Repository: "api", Number: 1},
+			{Org: "globex", Repository: "api", Number: 1},
+			{Org: "acme", Repository: "web", Number: 1},
+		},
+		OpenPRsWithActivity:    []PRStruct{{Org: "acme", Repository: "api", Number: 2}},
+		OpenPRsWithoutActivity: []PRStruct{{Org: "acme", Repository: "api", Number: 2}, {Org: "acme", Repository: "api", Number: 3}},
+	}
+	r.Deduplicate()
+	if len(r.MergedPRs) != 3 {
+		t.Errorf("MergedPRs = %+v, want 3 distinct pull requests", r.MergedPRs)
+	}
+	if len(r.OpenPRsWithActivity) != 1 || len(r.OpenPRsWithoutActivity) != 1 || r.OpenPRsWithoutActivity[0].Number != 3 {
+		t.Errorf("open with activity %+v, without %+v: want #2 with activity only", r.OpenPRsWithActivity, r.OpenPRsWithoutActivity)
+	}
+}
+
+func TestRunDecodesChurn(t *testing.T) {
+	server := newRepositoryServer(t, func(repo string) string {
+		return fmt.Sprintf(`"mergedPR":{"nodes":[
+			{"number":1,"mergedAt":%[1]q,"additions":10,"deletions":2},
+			{"number":2,"mergedAt":%[1]q,"additions":100,"deletions":50},
+			{"number":3,"mergedAt":%[1]q,"additions":1,"deletions":40}]}`, daysAgo(1))
+	}, "api")
+	report := newTestReport(server.URL)
+	if err := report.Run(); err != nil {
+		t.Fatal(err)
+	}
+	merged := append([]PRStruct{}, report.Result.MergedPRs...)
+	if len(merged) != 3 || merged[0].Additions != 10 || merged[0].Deletions != 2 || merged[0].TotalChurn() != 12 {
+		t.Fatalf("MergedPRs = %+v, want #1 with 10 additions and 2 deletions", merged)
+	}
+	sort.Sort(ByChurn(merged))
+	if got := numbers(merged); got != "[2 3 1]" {
+		t.Fatalf("ByChurn order = %s, want [2 3 1]", got)
+	}
+}
+
+func TestRunDecodesRequestedReviewers(t *testing.T) {
+	server := newRepositoryServer(t, func(repo string) string {
+		return fmt.Sprintf(`"openPR":{"nodes":[{"number":1,"state":"OPEN","createdAt":%q,"requestedReviewers":{"nodes":[
+			{"requestedReviewer":{"login":"alice"}},
+			{"requestedReviewer":{"slug":"core"}},
+			{"requestedReviewer":{}}]}}]}`, daysAgo(20))
+	}, "api")
+	report := newTestReport(server.URL)
+	if err := report.Run(); err != nil {
+		t.Fatal(err)
+	}
+	open := report.Result.OpenPRsWithoutActivity
+	if len(open) != 1 || fmt.Sprint(open[0].RequestedReviewers) != "[alice team:core]" {
+		t.Fatalf("OpenPRsWithoutActivity = %+v, want alice and team:core requested", open)
+	}
+}
+
+func TestRunCollectsStalePullRequests(t *testing.T) {
+	now := time.Now().UTC()
+	updated := func(age time.Duration) string { return now.Add(-age).Format(ISO_FORM) }
+	server := newRepositoryServer(t, func(repo string) string {
+		return fmt.Sprintf(`"openPR":{"nodes":[
+			{"number":1,"state":"OPEN","createdAt":%q,"updatedAt":%q},
+			{"number":2,"state":"OPEN","createdAt":%q,"updatedAt":%q},
+			{"number":3,"state":"OPEN","createdAt":%q},
+			{"number":4,"state":"OPEN","createdAt":%q,"updatedAt":%q,"activity":{"totalCount":2}}]}`,
+			daysAgo(90), updated(30*24*time.Hour+time.Hour),
+			daysAgo(90), updated(30*24*time.Hour-time.Hour),
+			daysAgo(40),
+			daysAgo(90), updated(31*24*time.Hour))
+	}, "api")
+	report := newTestReport(server.URL)
+	report.StaleAfter = 30 * 24 * time.Hour
+	if err := report.Run(); err != nil {
+		t.Fatal(err)
+	}
+	// #1 is just over the threshold, #2 just under, #3 falls back to its creation date,
+	// and #4 had activity during the window
+	if got := numbers(report.Result.StalePRs); got != "[1 3]" {
+		t.Fatalf("StalePRs = %s, want [1 3]", got)
+	}
+	if len(report.Result.OpenPRsWithoutActivity) != 3 {
+		t.Fatalf("OpenPRsWithoutActivity = %+v, want the stale ones too", report.Result.OpenPRsWithoutActivity)
+	}
+}
+
+func TestRunPassesRawResponsesToOnResponse(t *testing.T) {
+	repository := repositoryJSON("api", `"mergedPR":{"nodes":[],"futureField":"kept"}`)
+	server := newGraphQLServer(t, func(req graphQLRequest, w http.ResponseWriter) {
+		if isListing(req) {
+			fmt.Fprint(w, listingJSON("api"))
+			return
+		}
+		fmt.Fprint(w, repository)
+	})
+	report := newTestReport(server.URL)
+	var bodies []string
+	report.OnResponse = func(body []byte) { bodies = append(bodies, string(body)) }
+	if err := report.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if len(bodies) != 2 || bodies[0] != listingJSON("api") || bodies[1] != repository {
+		t.Fatalf("OnResponse received %q, want the listing and the repository bodies", bodies)
+	}
+}
+
+func TestRunPaginatesRefs(t *testing.T) {
+	var cursors []interface{}
+	server := newGraphQLServer(t, func(req graphQLRequest, w http.ResponseWriter) {
+		switch {
+		case isListing(req):
+			fmt.Fprint(w, listingJSON("api"))
+		case strings.Contains(req.Query, "refs(refPrefix: \"refs/heads/\", first: $size, after: $cursor)"):
+			cursors = append(cursors, req.Variables["cursor"])
+			fmt.Fprintf(w, `{"data":{"repository":{"refs":{"nodes":[{"name":"b2","target":{"history":{"nodes":[%s,%s]}}}],
+				"pageInfo":{"hasNextPage":false,"endCursor":"r2"}}}}}`,
+				commitJSON("c1", "alice", daysAgo(1), "feat: shared"), commitJSON("c2", "bob", daysAgo(1), "fix: second page"))
+		default:
+			fmt.Fprint(w, repositoryJSON("api", fmt.Sprintf(`"refs":{"nodes":[{"name":"b1","target":{"history":{"nodes":[%s]}}}],
+				"pageInfo":{"hasNextPage":true,"endCursor":"r1"}}`, commitJSON("c1", "alice", daysAgo(1), "feat: shared"))))
+		}
+	})
+	report := newTestReport(server.URL)
+	if err := report.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(cursors) != "[r1]" {
+		t.Errorf("cursors = %v, want [r1]", cursors)
+	}
+	if summary := report.Result.Commits["acme/api"]; summary.Commits != 2 || len(summary.Authors) != 2 {
+		t.Fatalf("Commits[acme/api] = %+v, want 2 commits by 2 authors", summary)
+	}
+}
+
+func TestRunUsesInjectedClient(t *testing.T) {
+	server := newRepositoryServer(t, func(repo string) string {
+		return fmt.Sprintf(`"mergedPR":{"nodes":[{"number":1,"mergedAt":%q}]}`, daysAgo(1))
+	}, "api")
+	report := NewActivityReport("acme", "token", 7)
+	report.BaseURL = "https://unused.invalid/graphql"
+	report.Client = graphql.NewClient(server.URL)
+	report.MaxRetries = 0
+	if err := report.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Result.MergedPRs) != 1 || report.Result.MergedPRs[0].Repository != "api" {
+		t.Fatalf("MergedPRs = %+v, want the pull request of api", report.Result.MergedPRs)
+	}
+}
+
+func TestInjectedClientReportsGraphQLErrors(t *testing.T) {
+	server := newGraphQLServer(t, func(req graphQLRequest, w http.ResponseWriter) {
+		fmt.Fprint(w, `{"data":null,"errors":[{"type":"FORBIDDEN","message":"Resource not accessible by integration"}]}`)
+	})
+	report := NewActivityReport("acme", "token", 7)
+	report.Client = graphql.NewClient(server.URL)
+	report.MaxRetries = 0
+	err := report.Run()
+	var graphQLErr *GraphQLError
+	if !errors.As(err, &graphQLErr) || fmt.Sprint(graphQLErr.Messages) != "[Resource not accessible by integration]" {
+		t.Fatalf("Run() = %v, want a *GraphQLError", err)
+	}
+}
+
+func TestValidateRejectsTransportOptionsWithClient(t *testing.T) {
+	for option, set := range map[string]func(*ActivityReport){
+		"UserAgent":        func(report *ActivityReport) { report.UserAgent = "agent" },
+		"RequestID":        func(report *ActivityReport) { report.RequestID = "id" },
+		"OnResponse":       func(report *ActivityReport) { report.OnResponse = func([]byte) {} },
+		"AllowPartialData": func(report *ActivityReport) { report.AllowPartialData = true },
+		"MaxRetries":       func(report *ActivityReport) { report.MaxRetries = 1 },
+	} {
+		report := NewActivityReport("acme", "token", 7)
+		report.Client = graphql.NewClient("https://unused.invalid/graphql")
+		report.MaxRetries = 0
+		if err := report.Validate(); err != nil {
+			t.Fatalf("Validate() = %v without %s", err, option)
+		}
+		set(report)
+		if err := report.Validate(); err == nil || !strings.HasPrefix(err.Error(), option) {
+			t.Errorf("Validate() = %v, want an error naming %s", err, option)
+		}
+	}
+}
+
+// newMergedPagesServer starts a test server listing api, whose merged pull requests span two
+// pages: #1 on the first one, and #2, merged during the window, on the second one.
+// The merged order and cursors received are appended to requests.
+func newMergedPagesServer(t *testing.T, requests *[]string) *httptest.Server {
+	return newGraphQLServer(t, func(req graphQLRequest, w http.ResponseWriter) {
+		if isListing(req) {
+			fmt.Fprint(w, listingJSON("api"))
+			return
+		}
+		*requests = append(*requests, fmt.Sprintf("%v %v", req.Variables["mergedOrder"], req.Variables["cursor"]))
+		if req.Variables["cursor"] == "m1" {
+			fmt.Fprintf(w, `{"data":{"repository":{"mergedPR":{"nodes":[
+				{"number":2,"mergedAt":%q,"updatedAt":%q},
+				{"number":3,"mergedAt":%q,"updatedAt":%q}],
+				"pageInfo":{"hasNextPage":true,"endCursor":"m2"}}}}}`, daysAgo(2), daysAgo(2), daysAgo(20), daysAgo(20))
+			return
+		}
+		fmt.Fprint(w, repositoryJSON("api", fmt.Sprintf(`"mergedPR":{"nodes":[{"number":1,"mergedAt":%q,"updatedAt":%q}],
+			"pageInfo":{"hasNextPage":true,"endCursor":"m1"}}`, daysAgo(1), daysAgo(1))))
+	})
+}
+
+func TestRunPaginatesMergedPullRequestsOverTheWindow(t *testing.T) {
+	requests := []string{}
+	report := newTestReport(newMergedPagesServer(t, &requests).URL)
+	if err := report.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if got := numbers(report.Result.MergedPRs); got != "[1 2]" {
+		t.Fatalf("MergedPRs = %s, want [1 2]", got)
+	}
+	// The third page is not fetched: #3 was last updated before the window
+	if fmt.Sprint(requests) != "[map[direction:DESC field:UPDATED_AT] <nil> map[direction:DESC field:UPDATED_AT] m1]" {
+		t.Fatalf("merged queries = %v, want the first two pages ordered by UPDATED_AT DESC", requests)
+	}
+}
+
+func TestRunFetchesOnePageOfMergedPullRequestsWithOtherOrders(t *testing.T) {
+	requests := []string{}
+	logger := &recordingLogger{}
+	report := newTestReport(newMergedPagesServer(t, &requests).URL)
+	report.MergedOrder = PROrder{Field: "CREATED_AT", Direction: "DESC"}
+	report.Logger = logger
+	if err := report.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if got := numbers(report.Result.MergedPRs); got != "[1]" {
+		t.Fatalf("MergedPRs = %s, want [1]", got)
+	}
+	if fmt.Sprint(requests) != "[map[direction:DESC field:CREATED_AT] <nil>]" {
+		t.Fatalf("merged queries = %v, want a single page ordered by CREATED_AT DESC", requests)
+	}
+	if !containsMessage(logger.messages, "WARN Only the first 1 merged pull requests of acme/api") {
+		t.Errorf("Logger messages = %q, want a warning for the missed pages", logger.messages)
+	}
+}
+
+func TestValidateRejectsAscendingMergedOrder(t *testing.T) {
+	for _, order := range []PROrder{{Field: "UPDATED_AT", Direction: "ASC"}, {Field: "CREATED_AT", Direction: "ASC"}, {Field: "COMMENTS", Direction: "DESC"}} {
+		report := NewActivityReport("acme", "token", 7)
+		report.MergedOrder = order
+		if err := report.Validate(); err == nil || !strings.Contains(err.Error(), "MergedOrder") {
+			t.Errorf("Validate() with %v = %v, want a MergedOrder error", order, err)
+		}
+	}
+}
+
+func TestRunFiltersByBaseBranch(t *testing.T) {
+	var baseBranches []interface{}
+	server := newGraphQLServer(t, func(req graphQLRequest, w http.ResponseWriter) {
+		if isListing(req) {
+			fmt.Fprint(w, listingJSON("api"))
+			return
+		}
+		baseBranches = append(baseBranches, req.Variables["baseBranch"])
+		if !strings.Contains(req.Query, "baseRefName: $baseBranch") {
+			t.Errorf("query without baseRefName: %s", req.Query)
+		}
+		// The server filters on the base branch like GitHub
+		nodes := ""
+		if req.Variables["baseBranch"] == nil || req.Variables["baseBranch"] == "main" {
+			nodes = fmt.Sprintf(`{"number":1,"mergedAt":%q}`, daysAgo(1))
+		}
+		fmt.Fprint(w, repositoryJSON("api", `"mergedPR":{"nodes":[`+nodes+`]}`))
+	})
+	for _, test := range []struct {
+		baseBranch string
+		merged     int
+	}{
+		{"", 1},
+		{"main", 1},
+		{"release", 0},
+	} {
+		baseBranches = nil
+		report := newTestReport(server.URL)
+		report.BaseBranch = test.baseBranch
+		if err := report.Run(); err != nil {
+			t.Fatal(err)
+		}
+		if len(report.Result.MergedPRs) != test.merged {
+			t.Errorf("BaseBranch %q: MergedPRs = %+v, want %d", test.baseBranch, report.Result.MergedPRs, test.merged)
+		}
+		want := interface{}(test.baseBranch)
+		if test.baseBranch == "" {
+			want = nil
+		}
+		if len(baseBranches) != 1 || baseBranches[0] != want {
+			t.Errorf("BaseBranch %q: baseBranch variables = %v, want %v", test.baseBranch, baseBranches, want)
+		}
+	}
+}
+
+func TestRunDecodesFirstReview(t *testing.T) {
+	server := newRepositoryServer(t, func(repo string) string {
+		return fmt.Sprintf(`"mergedPR":{"nodes":[
+			{"number":1,"createdAt":"2020-01-01T10:00:00Z","mergedAt":%[1]q,"firstReviewAt":{"nodes":[{"submittedAt":"2020-01-01T13:30:00Z"}]}},
+			{"number":2,"createdAt":"2020-01-01T10:00:00Z","mergedAt":%[1]q,"firstReviewAt":{"nodes":[]}}]}`, daysAgo(1))
+	}, "api")
+	report := newTestReport(server.URL)
+	if err := report.Run(); err != nil {
+		t.Fatal(err)
+	}
+	merged := report.Result.MergedPRs
+	if len(merged) != 2 {
+		t.Fatalf("MergedPRs = %+v", merged)
+	}
+	if merged[0].FirstReviewAt != "2020-01-01T13:30:00Z" || merged[0].TimeToFirstReview() != 3*time.Hour+30*time.Minute {
+		t.Errorf("#1 first reviewed at %q after %v, want 3h30m", merged[0].FirstReviewAt, merged[0].TimeToFirstReview())
+	}
+	if merged[1].FirstReviewAt != "" || merged[1].TimeToFirstReview() != 0 {
+		t.Errorf("#2 first reviewed at %q after %v, want no review", merged[1].FirstReviewAt, merged[1].TimeToFirstReview())
+	}
+}
+
+// countingTransport is an http.RoundTripper counting the requests it sends with http.DefaultTransport
+type countingTransport struct {
+	requests int32
+}
+
+func (t *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	atomic.AddInt32(&t.requests, 1)
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestRunUsesTransport(t *testing.T) {
+	server, authorizations := newHeaderServer(t, "Authorization")
+	transport := &countingTransport{}
+	report := newTestReport(server.URL)
+	report.Transport = transport
+	if err := report.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if n := atomic.LoadInt32(&transport.requests); n != 2 {
+		t.Fatalf("%d requests sent through Transport, want 2", n)
+	}
+	// The transport composes with the token authentication
+	if got := authorizations(); len(got) != 2 || got[0] != "Bearer token" {
+		t.Fatalf("Authorization headers = %v, want the token", got)
+	}
+}
+
+func TestRunCountsTotalAndScannedRepositories(t *testing.T) {
+	server := newGraphQLServer(t, func(req graphQLRequest, w http.ResponseWriter) {
+		if isListing(req) {
+			fmt.Fprint(w, `{"data":{"organization":{"repositories":{"nodes":[{"name":"api"},{"name":"web"}],
+				"pageInfo":{"hasNextPage":false},"totalCount":312}}}}`)
+			return
+		}
+		fmt.Fprint(w, repositoryJSON("api", ""))
+	})
+	report := newTestReport(server.URL)
+	report.FullScan = false
+	report.ExcludeRepos = []string{"web"}
+	if err := report.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if report.TotalRepositories != 312 || report.ScannedRepositories != 1 {
+		t.Fatalf("scanned %d of %d repositories, want 1 of 312", report.ScannedRepositories, report.TotalRepositories)
+	}
+}
+
+func TestRunDecodesMergeCommitOid(t *testing.T) {
+	server := newRepositoryServer(t, func(repo string) string {
+		return fmt.Sprintf(`"mergedPR":{"nodes":[{"number":1,"mergedAt":%q,"mergeCommitOid":{"oid":"abc123"}}]},
+			"openPR":{"nodes":[{"number":2,"state":"OPEN","createdAt":%q,"activity":{"totalCount":1}}]}`, daysAgo(1), daysAgo(1))
+	}, "api")
+	report := newTestReport(server.URL)
+	if err := report.Run(); err != nil {
+		t.Fatal(err)
+	}
+	merged, open := report.Result.MergedPRs, report.Result.OpenPRsWithActivity
+	if len(merged) != 1 || merged[0].MergeCommitOid != "abc123" {
+		t.Errorf("MergedPRs = %+v, want merge commit abc123", merged)
+	}
+	if len(open) != 1 || open[0].MergeCommitOid != "" {
+		t.Errorf("OpenPRsWithActivity = %+v, want no merge commit", open)
+	}
+}
+
+func TestRunCallsOnRepositoryReportOncePerReportedRepository(t *testing.T) {
+	report := newTestReport(newFailingRepositoryServer(t).URL)
+	report.SkipFailedRepos = true
+	calls := map[string]int{}
+	report.OnRepositoryReport = func(repo string, merged, openActive, openIdle []PRStruct) {
+		calls[repo]++
+		if len(merged) != 1 || merged[0].Repository != repo || len(openActive) != 0 || len(openIdle) != 0 {
+			t.Errorf("OnRepositoryReport(%s) with merged %+v, active %+v, idle %+v", repo, merged, openActive, openIdle)
+		}
+	}
+	if err := report.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(calls) != "map[api:1 web:1]" {
+		t.Fatalf("OnRepositoryReport calls = %v, want one for api and web", calls)
+	}
+}
+
+func TestRunBatchesRepositories(t *testing.T) {
+	var mu sync.Mutex
+	batches := []string{}
+	server := newGraphQLServer(t, func(req graphQLRequest, w http.ResponseWriter) {
+		if isListing(req) {
+			fmt.Fprint(w, listingJSON("api", "web", "docs", "site"))
+			return
+		}
+		// A batch of a single repository is reported with the regular query
+		if repo, ok := req.Variables["repo"].(string); ok {
+			mu.Lock()
+			batches = append(batches, repo)
+			mu.Unlock()
+			fmt.Fprint(w, repositoryJSON(repo, fmt.Sprintf(`"mergedPR":{"nodes":[{"number":1,"title":%q,"mergedAt":%q}]}`, repo, daysAgo(1))))
+			return
+		}
+		aliases := []string{}
+		repositories := []string{}
+		for i := 0; ; i++ {
+			repo, ok := req.Variables[fmt.Sprintf("repo%d", i)].(string)
+			if !ok {
+				break
+			}
+			aliases = append(aliases, fmt.Sprintf(`"repo%d":{"name":%q,"mergedPR":{"nodes":[{"number":%d,"title":%q,"mergedAt":%q}]}}`,
+				i, repo, i+1, repo, daysAgo(1)))
+			repositories = append(repositories, repo)
+		}
+		mu.Lock()
+		batches = append(batches, strings.Join(repositories, "+"))
+		mu.Unlock()
+		fmt.Fprintf(w, `{"data":{%s}}`, strings.Join(aliases, ","))
+	})
+	report := newTestReport(server.URL)
+	report.BatchSize = 3
+	if err := report.Run(); err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(batches)
+	if fmt.Sprint(batches) != "[api+web+docs site]" {
+		t.Fatalf("batches = %v, want api+web+docs and site", batches)
+	}
+	titles := []string{}
+	for _, pr := range report.Result.MergedPRs {
+		if pr.Title != pr.Repository {
+			t.Errorf("#%d %q parsed for repository %s", pr.Number, pr.Title, pr.Repository)
+		}
+		titles = append(titles, pr.Title)
+	}
+	if fmt.Sprint(titles) != "[api web docs site]" {
+		t.Fatalf("MergedPRs of %v, want api, web, docs and site in order", titles)
+	}
+}
+
+func TestRunFailsOnMissingBatchRepository(t *testing.T) {
+	for _, web := range []string{`,"repo1":null`, ``} {
+		server := newGraphQLServer(t, func(req graphQLRequest, w http.ResponseWriter) {
+			if isListing(req) {
+				fmt.Fprint(w, listingJSON("api", "web"))
+				return
+			}
+			fmt.Fprintf(w, `{"data":{"repo0":{"name":"api","mergedPR":{"nodes":[{"number":1,"mergedAt":%q}]}}%s}}`, daysAgo(1), web)
+		})
+		report := newTestReport(server.URL)
+		report.BatchSize = 2
+		err := report.Run()
+		var repoErr *RepositoryError
+		if !errors.As(err, &repoErr) || repoErr.Repository != "web" {
+			t.Fatalf("with %q, expected an error for web, got %v", web, err)
+		}
+
+		// The batch is skipped rather than reporting web as empty
+		report = newTestReport(server.URL)
+		report.BatchSize = 2
+		report.SkipFailedRepos = true
+		if err := report.Run(); err != nil {
+			t.Fatal(err)
+		}
+		if len(report.Result.Errors) != 2 || len(report.Result.MergedPRs) != 0 {
+			t.Fatalf("with %q, Errors = %v, MergedPRs = %+v", web, report.Result.Errors, report.Result.MergedPRs)
+		}
+	}
+}
+
+func TestWindowCoversDurationInLocation(t *testing.T) {
+	now := time.Date(2020, 1, 8, 23, 0, 0, 0, time.UTC)
+	report := NewActivityReport("acme", "token", 7)
+	report.Location = time.FixedZone("UTC+2", 2*60*60)
+
+	since, until := report.window(now)
+	if expected := time.Date(2020, 1, 1, 23, 0, 0, 0, time.UTC); !since.Equal(expected) {
+		t.Errorf("expected the window to start at %v, got %v", expected, since)
+	}
+	if !until.Equal(now) {
+		t.Errorf("expected the window to end at %v, got %v", now, until)
+	}
+	if since.Location() != report.Location || until.Location() != report.Location {
+		t.Errorf("expected the window in %v, got %v and %v", report.Location, since.Location(), until.Location())
+	}
+}
+
+func TestHumanDateUsesLocation(t *testing.T) {
+	report := NewActivityReport("acme", "token", 7)
+	report.Location = time.FixedZone("UTC+2", 2*60*60)
+	if got := report.humanDate("2020-01-01T23:00:00Z"); got != "2020-01-02 01:00" {
+		t.Errorf("expected 2020-01-02 01:00, got %q", got)
+	}
+}
+
+func TestValidateNamesInvalidField(t *testing.T) {
+	tests := []struct {
+		organization string
+		token        string
+		duration     int
+		field        string
+	}{
+		{"", "token", 7, "Organization"},
+		{"acme", "", 7, "token"},
+		{"acme", "token", 0, "Duration"},
+		{"acme", "token", -1, "Duration"},
+	}
+	for _, test := range tests {
+		report := NewActivityReport(test.organization, test.token, test.duration)
+		if err := report.Validate(); err == nil || !strings.Contains(err.Error(), test.field) {
+			t.Errorf("Validate() with %q, %q, %d = %v, want a %s error", test.organization, test.token, test.duration, err, test.field)
+		}
+	}
+}
+
+func TestRunValidatesBeforeQuerying(t *testing.T) {
+	queried := false
+	ts := newGraphQLServer(t, func(req graphQLRequest, w http.ResponseWriter) {
+		queried = true
+	})
+	report := newTestReport(ts.URL)
+	report.Duration = 0
+	if err := report.Run(); err == nil || !strings.Contains(err.Error(), "Duration") {
+		t.Errorf("expected a Duration error, got %v", err)
+	}
+	if queried {
+		t.Error("expected Run to fail before querying GitHub")
+	}
+}
+
+func TestReportForRepositoryMatchesRun(t *testing.T) {
+	server := newRepositoryServer(t, func(repo string) string {
+		return fmt.Sprintf(`"mergedPR":{"nodes":[{"number":1,"mergedAt":%q}]},
+			"openPR":{"nodes":[
+				{"number":2,"state":"OPEN","createdAt":%q,"activity":{"totalCount":3}},
+				{"number":3,"state":"OPEN","createdAt":%q,"activity":{"totalCount":0}}]},
+			"closedPR":{"nodes":[{"number":4,"state":"CLOSED","closedAt":%q}]}`,
+			daysAgo(1), daysAgo(20), daysAgo(20), daysAgo(1))
+	}, "api", "web")
+	report := newTestReport(server.URL)
+	if err := report.Run(); err != nil {
+		t.Fatal(err)
+	}
+	single, err := report.ReportForRepository(context.Background(), "api")
+	if err != nil {
+		t.Fatal(err)
+	}
+	onlyAPI := func(prs []PRStruct) []PRStruct {
+		filtered := []PRStruct{}
+		for _, pr := range prs {
+			if pr.Repository == "api" {
+				filtered = append(filtered, pr)
+			}
+		}
+		return filtered
+	}
+	for name, lists := range map[string][2][]PRStruct{
+		"MergedPRs":              {single.MergedPRs, report.Result.MergedPRs},
+		"OpenPRsWithActivity":    {single.OpenPRsWithActivity, report.Result.OpenPRsWithActivity},
+		"OpenPRsWithoutActivity": {single.OpenPRsWithoutActivity, report.Result.OpenPRsWithoutActivity},
+		"ClosedPRs":              {single.ClosedPRs, report.Result.ClosedPRs},
+	} {
+		if got, want := numbers(lists[0]), numbers(onlyAPI(lists[1])); got != want {
+			t.Errorf("%s = %s, want %s as in Run", name, got, want)
+		}
+	}
+}
+
+func TestReportForRepositoryRejectsEmptyName(t *testing.T) {
+	report := newTestReport("http://127.0.0.1:0")
+	if _, err := report.ReportForRepository(context.Background(), ""); err == nil {
+		t.Error("expected an error for an empty repository name")
+	}
+}
+
+func TestRunReportsConflictingPullRequests(t *testing.T) {
+	server := newRepositoryServer(t, func(repo string) string {
+		return fmt.Sprintf(`"openPR":{"nodes":[
+			{"number":1,"state":"OPEN","createdAt":%[1]q,"mergeable":"MERGEABLE","activity":{"totalCount":1}},
+			{"number":2,"state":"OPEN","createdAt":%[1]q,"mergeable":"CONFLICTING","activity":{"totalCount":1}},
+			{"number":3,"state":"OPEN","createdAt":%[1]q,"mergeable":"UNKNOWN","activity":{"totalCount":1}}]}`, daysAgo(2))
+	}, "api")
+	report := newTestReport(server.URL)
+	if err := report.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if got := numbers(report.Result.ConflictingPRs); got != "[2]" {
+		t.Errorf("ConflictingPRs = %s, want only the CONFLICTING one", got)
+	}
+	if got := numbers(report.Result.OpenPRsWithActivity); got != "[1 2 3]" {
+		t.Errorf("OpenPRsWithActivity = %s, want every open pull request", got)
+	}
+	for _, pr := range report.Result.OpenPRsWithActivity {
+		if pr.Mergeable == "" {
+			t.Errorf("#%d has no Mergeable state", pr.Number)
+		}
+	}
+}
+
+func TestRunPaginatesMergedPullRequestsOverALongWindow(t *testing.T) {
+	var mu sync.Mutex
+	pages := 0
+	server := newGraphQLServer(t, func(req graphQLRequest, w http.ResponseWriter) {
+		if isListing(req) {
+			fmt.Fprint(w, listingJSON("api"))
+			return
+		}
+		// Page k holds #2k+1 and #2k+2, merged 40k+10 and 40k+30 days ago, and always has a next page
+		mu.Lock()
+		page := pages
+		pages++
+		mu.Unlock()
+		nodes := fmt.Sprintf(`{"number":%d,"mergedAt":%[2]q,"updatedAt":%[2]q},{"number":%d,"mergedAt":%[4]q,"updatedAt":%[4]q}`,
+			2*page+1, daysAgo(40*page+10), 2*page+2, daysAgo(40*page+30))
+		connection := fmt.Sprintf(`"mergedPR":{"nodes":[%s],"pageInfo":{"hasNextPage":true,"endCursor":"m%d"}}`, nodes, page+1)
+		if page == 0 {
+			fmt.Fprint(w, repositoryJSON("api", connection))
+		} else {
+			fmt.Fprintf(w, `{"data":{"repository":{%s}}}`, connection)
+		}
+	})
+	report := newTestReport(server.URL)
+	report.Duration = 180
+	report.PageSize = 2
+	if err := report.Run(); err != nil {
+		t.Fatal(err)
+	}
+	// Page 4 ends with #10, merged 190 days ago: it is the last one fetched
+	if pages != 5 {
+		t.Errorf("fetched %d pages of merged pull requests, want 5", pages)
+	}
+	if got := numbers(report.Result.MergedPRs); got != "[1 2 3 4 5 6 7 8 9]" {
+		t.Errorf("MergedPRs = %s, want every pull request merged during the window", got)
+	}
+}
+
+func TestGroupByRepository(t *testing.T) {
+	pr := func(org string, repository string, number int) PRStruct {
+		return PRStruct{Org: org, Repository: repository, Number: number}
+	}
+	r := &Result{
+		MergedPRs:              []PRStruct{pr("acme", "api", 1), pr("acme", "web", 2)},
+		OpenPRsWithActivity:    []PRStruct{pr("acme", "api", 3), pr("globex", "api", 4)},
+		OpenPRsWithoutActivity: []PRStruct{pr("acme", "web", 5)},
+		ClosedPRs:              []PRStruct{pr("", "cli", 6)},
+		Issues:                 []IssueStruct{{Org: "acme", Repository: "web", Number: 7}},
+	}
+	groups := r.GroupByRepository()
+	if len(groups) != 4 {
+		t.Fatalf("GroupByRepository() has %d groups, want acme/api, acme/web, globex/api and cli", len(groups))
+	}
+	for repository, expected := range map[string][3]string{
+		"acme/api":   {"[1]", "[3]", "[]"},
+		"acme/web":   {"[2]", "[]", "[5]"},
+		"globex/api": {"[]", "[4]", "[]"},
+		"cli":        {"[]", "[]", "[]"},
+	} {
+		g := groups[repository]
+		if g == nil {
+			t.Errorf("no group for %s", repository)
+			continue
+		}
+		got := [3]string{numbers(g.MergedPRs), numbers(g.OpenPRsWithActivity), numbers(g.OpenPRsWithoutActivity)}
+		if got != expected {
+			t.Errorf("%s: merged, open with and without activity = %v, want %v", repository, got, expected)
+		}
+	}
+	if len(groups["cli"].ClosedPRs) != 1 || len(groups["acme/web"].Issues) != 1 {
+		t.Errorf("closed pull requests and issues are not grouped: %+v", groups)
+	}
+	if got := numbers(r.MergedPRs); got != "[1 2]" {
+		t.Errorf("MergedPRs modified to %s", got)
+	}
+}
+
+func TestRunReportsOrganizationNotFound(t *testing.T) {
+	for name, response := range map[string]string{
+		"error":        `{"data":{"organization":null},"errors":[{"type":"NOT_FOUND","path":["organization"],"message":"Could not resolve to an Organization with the login of 'acme'."}]}`,
+		"null":         `{"data":{"organization":null}}`,
+		"message only": `{"data":null,"errors":[{"message":"Could not resolve to an Organization with the login of 'acme'."}]}`,
+	} {
+		server := newGraphQLServer(t, func(req graphQLRequest, w http.ResponseWriter) {
+			fmt.Fprint(w, response)
+		})
+		for _, fullScan := range []bool{true, false} {
+			report := newTestReport(server.URL)
+			report.FullScan = fullScan
+			if err := report.Run(); !errors.Is(err, ErrOrganizationNotFound) {
+				t.Errorf("%s (FullScan %v): expected ErrOrganizationNotFound, got %v", name, fullScan, err)
+			}
+		}
+	}
+}
+
+func TestRunWithOrganizationWithoutRepositories(t *testing.T) {
+	server := newRepositoryServer(t, func(repo string) string { return "" })
+	for _, fullScan := range []bool{true, false} {
+		report := newTestReport(server.URL)
+		report.FullScan = fullScan
+		if err := report.Run(); err != nil {
+			t.Fatalf("FullScan %v: expected an empty report, got %v", fullScan, err)
+		}
+		if report.TotalRepositories != 0 || len(report.Result.MergedPRs) != 0 {
+			t.Errorf("FullScan %v: expected an empty report, got %+v", fullScan, report.Result)
+		}
+	}
+}
+
+func TestCommitType(t *testing.T) {
+	for message, expected := range map[string]string{
+		"feat: add search":              "feat",
+		"fix(api): handle nil":          "fix",
+		"Chore: bump deps":              "chore",
+		" FEAT !: breaking change":      "feat",
+		"refactor!: drop the old API":   "refactor",
+		"Merge pull request #12":        "unknown",
+		"Update README.md":              "unknown",
+		"":                              "unknown",
+		"docs: explain\n\nthe new flag": "docs",
+	} {
+		if got := commitType(message); got != expected {
+			t.Errorf("commitType(%q) = %q, want %q", message, got, expected)
+		}
+	}
+}
+
+func TestCommitTypeBreakdown(t *testing.T) {
+	r := &Result{Commits: map[string]CommitSummary{
+		"acme/api": {Types: map[string]int{"feat": 2, "unknown": 1}},
+		"acme/web": {Types: map[string]int{"feat": 1, "fix": 3}},
+	}}
+	if got := fmt.Sprint(r.CommitTypeBreakdown()); got != "map[feat:3 fix:3 unknown:1]" {
+		t.Errorf("CommitTypeBreakdown() = %s, want map[feat:3 fix:3 unknown:1]", got)
+	}
+}
+
+func TestRunClassifiesWithStateMapping(t *testing.T) {
+	server := newRepositoryServer(t, func(repo string) string {
+		return fmt.Sprintf(`"mergedPR":{"nodes":[
+				{"number":1,"state":"MERGED","mergedAt":%[1]q},
+				{"number":2,"state":"MERGED","mergedAt":%[2]q}]},
+			"openPR":{"nodes":[{"number":3,"state":"OPEN","createdAt":%[1]q,"activity":{"totalCount":1}}]},
+			"closedPR":{"nodes":[
+				{"number":4,"state":"CLOSED","closedAt":%[1]q},
+				{"number":5,"state":"CLOSED","closedAt":%[2]q}]}`, daysAgo(1), daysAgo(30))
+	}, "api")
+	tests := []struct {
+		mapping                map[string]PRBucket
+		merged, open, closedPR string
+	}{
+		{DefaultStateMapping, "[1]", "[3]", "[4]"},
+		// Merged pull requests counted as closed are bounded by their merge date
+		{map[string]PRBucket{StateMerged: BucketClosed, StateOpen: BucketOpen, StateClosed: BucketClosed}, "[]", "[3]", "[1 4]"},
+		{map[string]PRBucket{StateMerged: BucketMerged, StateOpen: BucketOpen, StateClosed: BucketMerged}, "[1 4]", "[3]", "[]"},
+		{map[string]PRBucket{StateMerged: BucketMerged}, "[1]", "[]", "[]"},
+	}
+	for _, test := range tests {
+		report := newTestReport(server.URL)
+		report.StateMapping = test.mapping
+		if err := report.Run(); err != nil {
+			t.Fatal(err)
+		}
+		got := []string{numbers(report.Result.MergedPRs), numbers(report.Result.OpenPRsWithActivity), numbers(report.Result.ClosedPRs)}
+		if fmt.Sprint(got) != fmt.Sprint([]string{test.merged, test.open, test.closedPR}) {
+			t.Errorf("with %v: merged, open and closed = %v, want %s %s %s", test.mapping, got, test.merged, test.open, test.closedPR)
+		}
+	}
+}
+
+func TestValidateRejectsInvalidStateMapping(t *testing.T) {
+	for _, mapping := range []map[string]PRBucket{
+		{"DRAFT": BucketOpen},
+		{StateMerged: "archived"},
+		{StateOpen: BucketMerged},
+		{StateOpen: BucketClosed},
+	} {
+		report := NewActivityReport("acme", "token", 7)
+		report.StateMapping = mapping
+		if err := report.Validate(); err == nil || !strings.Contains(err.Error(), "StateMapping") {
+			t.Errorf("Validate() with %v = %v, want a StateMapping error", mapping, err)
+		}
+	}
+}
+
+func TestPRAge(t *testing.T) {
+	now := time.Date(2020, 1, 8, 12, 0, 0, 0, time.UTC)
+	pr := PRStruct{CreatedAt: "2020-01-01T00:00:00Z"}
+	if age, ok := pr.Age(now); !ok || age != 7*24*time.Hour+12*time.Hour {
+		t.Errorf("Age() = %v, %v, want 180h, true", age, ok)
+	}
+	if age, ok := (PRStruct{CreatedAt: "2020-01-01"}).Age(now); ok || age != 0 {
+		t.Errorf("Age() of an unparseable date = %v, %v, want 0, false", age, ok)
+	}
+
+	report := NewActivityReport("acme", "token", 7)
+	if _, ok := report.PRAge(pr); ok {
+		t.Error("PRAge() before the report ran should not be ok")
+	}
+	report.Result.ReportDate = now
+	if age, ok := report.PRAge(pr); !ok || age != 180*time.Hour {
+		t.Errorf("PRAge() = %v, %v, want 180h, true", age, ok)
+	}
+}
+
+func TestRunCountsRecentComments(t *testing.T) {
+	server := newRepositoryServer(t, func(repo string) string {
+		return fmt.Sprintf(`"openPR":{"nodes":[{"number":1,"state":"OPEN","createdAt":%q,"activity":{"totalCount":4},
+			"recentCommentCount":{"totalCount":2},
+			"recentReviewCommentCount":{"nodes":[{"comments":{"totalCount":3}},{"comments":{"totalCount":0}},{"comments":{"totalCount":4}}]}}]}`,
+			daysAgo(20))
+	}, "api")
+	report := newTestReport(server.URL)
+	if err := report.Run(); err != nil {
+		t.Fatal(err)
+	}
+	open := report.Result.OpenPRsWithActivity
+	if len(open) != 1 {
+		t.Fatalf("OpenPRsWithActivity = %+v", open)
+	}
+	if open[0].RecentCommentCount != 2 || open[0].RecentReviewCommentCount != 7 {
+		t.Errorf("comments = %d, review comments = %d, want 2 and 7", open[0].RecentCommentCount, open[0].RecentReviewCommentCount)
+	}
+}
+
+func TestCommentCountRoundTrip(t *testing.T) {
+	pr := PRStruct{Number: 1, RecentCommentCount: 2, RecentReviewCommentCount: 7}
+	data, err := json.Marshal(pr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded PRStruct
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.RecentCommentCount != 2 || decoded.RecentReviewCommentCount != 7 {
+		t.Errorf("decoded %s to comments %d and review comments %d", data, decoded.RecentCommentCount, decoded.RecentReviewCommentCount)
+	}
+}
+
+func TestRunUsesCustomQueries(t *testing.T) {
+	var mu sync.Mutex
+	var bodies []string
+	server := newGraphQLServer(t, func(req graphQLRequest, w http.ResponseWriter) {
+		if isListing(req) {
+			if !strings.Contains(req.Query, "isArchived") {
+				t.Errorf("listing query %q is not the custom one", req.Query)
+			}
+			fmt.Fprint(w, `{"data":{"organization":{"repositories":{"nodes":[{"name":"api","isArchived":false}],"totalCount":1}}}}`)
+			return
+		}
+		if !strings.Contains(req.Query, "projectsV2") {
+			t.Errorf("repository query %q is not the custom one", req.Query)
+		}
+		fmt.Fprint(w, repositoryJSON("api", fmt.Sprintf(`"projectsV2":{"totalCount":3},"mergedPR":{"nodes":[{"number":1,"mergedAt":%q}]}`, daysAgo(1))))
+	})
+	report := newTestReport(server.URL)
+	report.RepositoriesQuery = strings.Replace(DefaultRepositoriesQuery, "nodes {", "nodes {\n          isArchived", 1)
+	report.RepositoryQuery = strings.Replace(DefaultRepositoryQuery, "...repositoryFields", "...repositoryFields\n    projectsV2 { totalCount }", 1)
+	report.OnResponse = func(body []byte) {
+		mu.Lock()
+		defer mu.Unlock()
+		bodies = append(bodies, string(body))
+	}
+	if err := report.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Result.MergedPRs) != 1 {
+		t.Errorf("MergedPRs = %+v, want the known fields to be decoded", report.Result.MergedPRs)
+	}
+	if len(bodies) != 2 || !strings.Contains(bodies[1], `"projectsV2":{"totalCount":3}`) {
+		t.Errorf("OnResponse received %q, want the extra fields", bodies)
+	}
+}
+
+func TestPRStructDecodesGraphQL(t *testing.T) {
+	data := `{
+		"labels":{"nodes":[{"name":"bug"}]},
+		"changedFiles":{"nodes":[{"path":"main.go"}]},
+		"requestedReviewers":{"nodes":[{"requestedReviewer":{"login":"alice"}},{"requestedReviewer":{"slug":"core"}},{"requestedReviewer":{}}]},
+		"firstReviewAt":{"nodes":[{"submittedAt":"2020-01-02T00:00:00Z"}]},
+		"lastCommitAt":{"nodes":[{"commit":{"committedDate":"2020-01-03T00:00:00Z"}}]},
+		"mergeCommitOid":{"oid":"abc123"},
+		"recentCommentCount":{"totalCount":2},
+		"recentReviewCommentCount":{"nodes":[{"comments":{"totalCount":1}},{"comments":{"totalCount":4}}]}
+	}`
+	var pr PRStruct
+	if err := json.Unmarshal([]byte(data), &pr); err != nil {
+		t.Fatal(err)
+	}
+	expected := PRStruct{
+		Labels:                   LabelList{"bug"},
+		ChangedFiles:             FileList{"main.go"},
+		RequestedReviewers:       ReviewerList{"alice", "team:core"},
+		FirstReviewAt:            "2020-01-02T00:00:00Z",
+		LastCommitAt:             "2020-01-03T00:00:00Z",
+		MergeCommitOid:           "abc123",
+		RecentCommentCount:       2,
+		RecentReviewCommentCount: 5,
+	}
+	if !reflect.DeepEqual(pr, expected) {
+		t.Errorf("decoded %+v, want %+v", pr, expected)
+	}
+}
+
+func TestPRStructJSONRoundTrip(t *testing.T) {
+	pr := PRStruct{
+		Number:                   1,
+		Labels:                   LabelList{"bug"},
+		ChangedFiles:             FileList{"main.go"},
+		RequestedReviewers:       ReviewerList{"alice", "team:core"},
+		FirstReviewAt:            "2020-01-02T00:00:00Z",
+		LastCommitAt:             "2020-01-03T00:00:00Z",
+		MergeCommitOid:           "abc123",
+		RecentCommentCount:       2,
+		RecentReviewCommentCount: 5,
+	}
+	data, err := json.Marshal(pr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded PRStruct
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded, pr) {
+		t.Errorf("decoded %s to %+v, want %+v", data, decoded, pr)
+	}
+
+	// Empty values, e.g. of an unmerged pull request without review, are written as null or ""
+	data, err = json.Marshal(PRStruct{Number: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded = PRStruct{}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded, PRStruct{Number: 2}) {
+		t.Errorf("decoded %s to %+v, want an empty pull request", data, decoded)
+	}
+}
+
+// closingTransport is an http.RoundTripper counting the calls to CloseIdleConnections
+type closingTransport struct {
+	closed int32
+}
+
+func (t *closingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func (t *closingTransport) CloseIdleConnections() {
+	atomic.AddInt32(&t.closed, 1)
+}
+
+func TestGenerateReusesClient(t *testing.T) {
+	server := newRepositoryServer(t, func(repo string) string { return "" }, "api")
+	transport := &closingTransport{}
+	report := newTestReport(server.URL)
+	report.Transport = transport
+
+	if _, err := report.Generate(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	first := report.client
+	if _, err := report.Generate(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if first == nil || report.client != first {
+		t.Fatal("expected the second run to reuse the client of the first one")
+	}
+
+	// Changing the endpoint replaces the client
+	other := newRepositoryServer(t, func(repo string) string { return "" }, "web")
+	report.BaseURL = other.URL
+	if _, err := report.Generate(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if report.client == first {
+		t.Error("expected a new client for the new BaseURL")
+	}
+
+	if err := report.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if report.client != nil {
+		t.Error("expected Close to release the client")
+	}
+	if atomic.LoadInt32(&transport.closed) == 0 {
+		t.Error("expected Close to close the idle connections of Transport")
+	}
+	// The report can still run after Close
+	if _, err := report.Generate(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRunCountsRecentCommitsAsActivity(t *testing.T) {
+	server := newRepositoryServer(t, func(repo string) string {
+		return fmt.Sprintf(`"openPR":{"nodes":[
+			{"number":1,"state":"OPEN","createdAt":%[1]q,"activity":{"totalCount":0},
+				"lastCommitAt":{"nodes":[{"commit":{"committedDate":%[2]q}}]}},
+			{"number":2,"state":"OPEN","createdAt":%[1]q,"activity":{"totalCount":0},
+				"lastCommitAt":{"nodes":[{"commit":{"committedDate":%[1]q}}]}},
+			{"number":3,"state":"OPEN","createdAt":%[1]q,"activity":{"totalCount":1}}]}`, daysAgo(30), daysAgo(1))
+	}, "api")
+	for _, count := range []bool{true, false} {
+		report := newTestReport(server.URL)
+		report.CountRecentCommits = count
+		report.ActivityThreshold = 2
+		if err := report.Run(); err != nil {
+			t.Fatal(err)
+		}
+		active, idle := numbers(report.Result.OpenPRsWithActivity), numbers(report.Result.OpenPRsWithoutActivity)
+		if count && (active != "[1]" || idle != "[2 3]") {
+			t.Errorf("CountRecentCommits: active %s and idle %s, want [1] and [2 3]", active, idle)
+		}
+		if !count && (active != "[]" || idle != "[1 2 3]") {
+			t.Errorf("active %s and idle %s, want [] and [1 2 3]", active, idle)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package ghreport
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+)
+
+// Reporter publishes the result of a report, e.g. to a file or an HTTP endpoint
+type Reporter interface {
+	Publish(ctx context.Context, result *Result) error
+}
+
+// FileReporter is a Reporter writing the result as an indented JSON document to a file
+type FileReporter struct {
+	// Path is the file written, replaced if it exists
+	Path string
+}
+
+// Publish implements Reporter
+func (r *FileReporter) Publish(ctx context.Context, result *Result) error {
+	data, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(r.Path, append(data, '\n'), 0644)
+}
+
+// HTTPReporter is a Reporter posting the result as a JSON document to a URL
+type HTTPReporter struct {
+	// URL is the endpoint receiving the POST request
+	URL string
+	// Header holds additional request headers, e.g. for authentication
+	Header http.Header
+	// Client sends the request. When nil, http.DefaultClient is used.
+	Client *http.Client
+}
+
+// Publish implements Reporter. Responses other than 2xx are returned as errors.
+func (r *HTTPReporter) Publish(ctx context.Context, result *Result) error {
+	data, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.URL, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	for name, values := range r.Header {
+		for _, value := range values {
+			req.Header.Add(name, value)
+		}
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	client := r.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("%s returned %s", r.URL, resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+package ghreport
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// recordingReporter is a Reporter keeping the results it publishes
+type recordingReporter struct {
+	published []*Result
+}
+
+func (r *recordingReporter) Publish(ctx context.Context, result *Result) error {
+	r.published = append(r.published, result)
+	return nil
+}
+
+func newMergedByServer(t *testing.T, login string) *httptest.Server {
+	return newRepositoryServer(t, func(repo string) string {
+		return fmt.Sprintf(`"mergedPR":{"nodes":[{"number":1,"mergedAt":%q,"author":{"login":%q}}]}`, daysAgo(1), login)
+	}, "api")
+}
+
+func TestRunPublishesResult(t *testing.T) {
+	reporter := &recordingReporter{}
+	report := newTestReport(newMergedByServer(t, "alice").URL)
+	report.Reporter = reporter
+	if err := report.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if len(reporter.published) != 1 || len(reporter.published[0].MergedPRs) != 1 {
+		t.Fatalf("published %+v, want the result once", reporter.published)
+	}
+}
+
+func TestRunPublishesAnonymizedResult(t *testing.T) {
+	reporter := &recordingReporter{}
+	report := newTestReport(newMergedByServer(t, "alice").URL)
+	report.Reporter = reporter
+	report.Anonymize = true
+	if err := report.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if len(reporter.published) != 1 {
+		t.Fatalf("published %d results, want 1", len(reporter.published))
+	}
+	if login := reporter.published[0].MergedPRs[0].Author.Login; login == "alice" {
+		t.Error("expected the published result to be anonymized")
+	}
+	if login := report.Result.MergedPRs[0].Author.Login; login != "alice" {
+		t.Errorf("expected the result of the report to be left untouched, got %q", login)
+	}
+}
+
+func TestRunDoesNotPublishIncompleteResult(t *testing.T) {
+	reporter := &recordingReporter{}
+	report := newTestReport(newFailingRepositoryServer(t).URL)
+	report.Reporter = reporter
+	if err := report.Run(); err == nil {
+		t.Fatal("expected the run to fail")
+	}
+	if len(reporter.published) != 0 {
+		t.Errorf("published %d results, want none", len(reporter.published))
+	}
+}
+
+func TestFileReporter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "report.json")
+	result := &Result{MergedPRs: []PRStruct{{Number: 1, Title: "Fix"}}}
+	if err := (&FileReporter{Path: path}).Publish(context.Background(), result); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded Result
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("invalid JSON %q: %v", data, err)
+	}
+	if len(decoded.MergedPRs) != 1 || decoded.MergedPRs[0].Title != "Fix" {
+		t.Errorf("MergedPRs = %+v", decoded.MergedPRs)
+	}
+}
+
+func TestHTTPReporter(t *testing.T) {
+	var authorization string
+	var decoded Result
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization = r.Header.Get("Authorization")
+		json.NewDecoder(r.Body).Decode(&decoded)
+	}))
+	defer server.Close()
+	reporter := &HTTPReporter{URL: server.URL, Header: http.Header{"Authorization": []string{"Bearer secret"}}}
+	result := &Result{MergedPRs: []PRStruct{{Number: 1}}}
+	if err := reporter.Publish(context.Background(), result); err != nil {
+		t.Fatal(err)
+	}
+	if authorization != "Bearer secret" || len(decoded.MergedPRs) != 1 {
+		t.Errorf("received Authorization %q and %+v", authorization, decoded)
+	}
+}
+
+func TestHTTPReporterFailsOnErrorStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+	if err := (&HTTPReporter{URL: server.URL}).Publish(context.Background(), &Result{}); err == nil {
+		t.Error("expected an error for a 502 response")
+	}
+}
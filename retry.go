@@ -0,0 +1,151 @@
+package ghreport
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/dsciamma/graphql"
+)
+
+// DefaultMaxRetries is the number of times a failing query is retried by default
+const DefaultMaxRetries = 3
+
+// DefaultRetryDelay is the delay before the first retry, doubled for each following retry
+const DefaultRetryDelay = time.Second
+
+// ErrRequestTimeout is returned when a single query exceeds RequestTimeout
+var ErrRequestTimeout = errors.New("GitHub request timed out")
+
+// httpStatusError is returned by statusTransport when GitHub answers with a server error,
+// or with a secondary rate limit asking to retry after RetryAfter
+type httpStatusError struct {
+	StatusCode int
+	Status     string
+	RetryAfter time.Duration
+}
+
+func (e *httpStatusError) Error() string {
+	return fmt.Sprintf("GitHub returned %s", e.Status)
+}
+
+// statusTransport turns 5xx responses, and 403/429 responses with a Retry-After header,
+// into errors so they can be told apart from other failures
+type statusTransport struct {
+	base http.RoundTripper
+}
+
+func (t *statusTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 500 {
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		return nil, &httpStatusError{StatusCode: resp.StatusCode, Status: resp.Status}
+	}
+	if resp.StatusCode == http.StatusForbidden || resp.StatusCode == http.StatusTooManyRequests {
+		if delay, ok := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()); ok {
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+			return nil, &httpStatusError{StatusCode: resp.StatusCode, Status: resp.Status, RetryAfter: delay}
+		}
+	}
+	return resp, nil
+}
+
+// parseRetryAfter reads a Retry-After header, given either in seconds or as an HTTP date
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		delay := t.Sub(now)
+		if delay < 0 {
+			delay = 0
+		}
+		return delay, true
+	}
+	return 0, false
+}
+
+// retryAfter returns the delay requested by GitHub through a Retry-After header, if any
+func retryAfter(err error) (time.Duration, bool) {
+	var statusErr *httpStatusError
+	if errors.As(err, &statusErr) && statusErr.StatusCode < 500 {
+		return statusErr.RetryAfter, true
+	}
+	return 0, false
+}
+
+// isRetryable reports whether err is a transient failure (server error, secondary rate limit
+// or network timeout). Queries exceeding RequestTimeout are only retried with RetryTimeouts.
+func (gr *ActivityReport) isRetryable(err error) bool {
+	if errors.Is(err, ErrRequestTimeout) {
+		return gr.RetryTimeouts
+	}
+	var statusErr *httpStatusError
+	if errors.As(err, &statusErr) {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return netErr.Timeout()
+	}
+	return false
+}
+
+// runQuery runs req with client, retrying transient failures with an exponential backoff.
+// When GitHub asks to retry after a given delay (secondary rate limit), that delay is used instead.
+func (gr *ActivityReport) runQuery(ctx context.Context, client *graphql.Client, req *graphql.Request, resp interface{}) error {
+	delay := gr.RetryDelay
+	for attempt := 0; ; attempt++ {
+		err := gr.runQueryOnce(ctx, client, req, resp)
+		if err == nil || attempt >= gr.MaxRetries || ctx.Err() != nil || !gr.isRetryable(err) {
+			return err
+		}
+		wait := delay
+		if after, ok := retryAfter(err); ok {
+			wait = after
+		} else {
+			delay *= 2
+		}
+		gr.warnf("Retrying in %v after error: %v\n", wait, err)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+}
+
+// runQueryOnce runs req with client, within RequestTimeout when set
+func (gr *ActivityReport) runQueryOnce(ctx context.Context, client *graphql.Client, req *graphql.Request, resp interface{}) error {
+	if err := gr.pace(ctx); err != nil {
+		return err
+	}
+	errs := &graphQLErrors{}
+	ctx = withGraphQLErrors(ctx, errs)
+	if gr.RequestTimeout <= 0 {
+		return gr.checkGraphQLErrors(client.Run(ctx, req, resp), errs)
+	}
+	queryCtx, cancel := context.WithTimeout(ctx, gr.RequestTimeout)
+	defer cancel()
+	err := client.Run(queryCtx, req, resp)
+	if err != nil && ctx.Err() == nil && queryCtx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("%w after %v: %v", ErrRequestTimeout, gr.RequestTimeout, err)
+	}
+	return gr.checkGraphQLErrors(err, errs)
+}
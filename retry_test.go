@@ -0,0 +1,183 @@
+package ghreport
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRunRetriesServerErrors(t *testing.T) {
+	var calls int32
+	server := newGraphQLServer(t, func(req graphQLRequest, w http.ResponseWriter) {
+		if atomic.AddInt32(&calls, 1) <= 2 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		if isListing(req) {
+			fmt.Fprint(w, listingJSON("api"))
+			return
+		}
+		fmt.Fprint(w, repositoryJSON("api", `"openPR":{"nodes":[{"number":1,"activity":{"totalCount":2}}]}`))
+	})
+	report := newTestReport(server.URL)
+	if err := report.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Result.OpenPRsWithActivity) != 1 {
+		t.Fatalf("OpenPRsWithActivity = %+v", report.Result.OpenPRsWithActivity)
+	}
+	if calls != 4 {
+		t.Fatalf("%d queries sent, want 2 failures, the listing and the repository", calls)
+	}
+}
+
+func TestRunGivesUpAfterMaxRetries(t *testing.T) {
+	var calls int32
+	server := newGraphQLServer(t, func(req graphQLRequest, w http.ResponseWriter) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+	report := newTestReport(server.URL)
+	report.MaxRetries = 2
+	if err := report.Run(); err == nil {
+		t.Fatal("Run() succeeded with a failing server")
+	}
+	if calls != 3 {
+		t.Fatalf("%d queries sent, want 1 and 2 retries", calls)
+	}
+}
+
+func TestRunDoesNotRetryClientErrors(t *testing.T) {
+	var calls int32
+	server := newGraphQLServer(t, func(req graphQLRequest, w http.ResponseWriter) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusNotFound)
+	})
+	report := newTestReport(server.URL)
+	if err := report.Run(); err == nil {
+		t.Fatal("Run() succeeded with a failing server")
+	}
+	if calls != 1 {
+		t.Fatalf("%d queries sent, want 1", calls)
+	}
+}
+
+// newSlowRepositoryServer starts a test server listing api and web, where the query of web
+// takes delay, and counting its queries in slowQueries
+func newSlowRepositoryServer(t *testing.T, delay time.Duration, slowQueries *int32) *httptest.Server {
+	return newGraphQLServer(t, func(req graphQLRequest, w http.ResponseWriter) {
+		if isListing(req) {
+			fmt.Fprint(w, listingJSON("api", "web"))
+			return
+		}
+		repo, _ := req.Variables["repo"].(string)
+		if repo == "web" {
+			atomic.AddInt32(slowQueries, 1)
+			time.Sleep(delay)
+		}
+		fmt.Fprint(w, repositoryJSON(repo, fmt.Sprintf(`"mergedPR":{"nodes":[{"number":1,"mergedAt":%q}]}`, daysAgo(1))))
+	})
+}
+
+func TestRunFailsFastOnRequestTimeout(t *testing.T) {
+	var slowQueries int32
+	report := newTestReport(newSlowRepositoryServer(t, 200*time.Millisecond, &slowQueries).URL)
+	report.RequestTimeout = 20 * time.Millisecond
+	report.SkipFailedRepos = true
+	if err := report.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Result.MergedPRs) != 1 || report.Result.MergedPRs[0].Repository != "api" {
+		t.Fatalf("MergedPRs = %+v, want the pull request of api", report.Result.MergedPRs)
+	}
+	if len(report.Result.Errors) != 1 || !errors.Is(report.Result.Errors[0], ErrRequestTimeout) {
+		t.Fatalf("Errors = %v, want ErrRequestTimeout", report.Result.Errors)
+	}
+	if n := atomic.LoadInt32(&slowQueries); n != 1 {
+		t.Fatalf("%d queries of the slow repository, want no retry", n)
+	}
+}
+
+func TestRunRetriesTimeoutsWhenEnabled(t *testing.T) {
+	var slowQueries int32
+	report := newTestReport(newSlowRepositoryServer(t, 100*time.Millisecond, &slowQueries).URL)
+	report.RequestTimeout = 20 * time.Millisecond
+	report.RetryTimeouts = true
+	report.MaxRetries = 2
+	if err := report.Run(); !errors.Is(err, ErrRequestTimeout) {
+		t.Fatalf("Run() = %v, want ErrRequestTimeout", err)
+	}
+	if n := atomic.LoadInt32(&slowQueries); n != 3 {
+		t.Fatalf("%d queries of the slow repository, want 3", n)
+	}
+}
+
+func TestRunWaitsForRetryAfter(t *testing.T) {
+	var calls int32
+	server := newGraphQLServer(t, func(req graphQLRequest, w http.ResponseWriter) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		if isListing(req) {
+			fmt.Fprint(w, listingJSON("api"))
+			return
+		}
+		fmt.Fprint(w, repositoryJSON("api", ""))
+	})
+	report := newTestReport(server.URL)
+	start := time.Now()
+	if err := report.Run(); err != nil {
+		t.Fatal(err)
+	}
+	// RetryDelay is a millisecond: the second attempt waited for Retry-After
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Errorf("Run() took %v, want at least the second requested by Retry-After", elapsed)
+	}
+	if calls != 3 {
+		t.Errorf("%d queries sent, want the rate limited one, the listing and the repository", calls)
+	}
+}
+
+func TestRunDoesNotRetryForbiddenWithoutRetryAfter(t *testing.T) {
+	var calls int32
+	server := newGraphQLServer(t, func(req graphQLRequest, w http.ResponseWriter) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusForbidden)
+	})
+	report := newTestReport(server.URL)
+	if err := report.Run(); err == nil {
+		t.Fatal("Run() succeeded with a failing server")
+	}
+	if calls != 1 {
+		t.Errorf("%d queries sent, want 1", calls)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		value string
+		delay time.Duration
+		ok    bool
+	}{
+		{"30", 30 * time.Second, true},
+		{"0", 0, true},
+		{"-1", 0, false},
+		{"Wed, 01 Jan 2020 00:00:45 GMT", 45 * time.Second, true},
+		{"Tue, 31 Dec 2019 23:00:00 GMT", 0, true},
+		{"soon", 0, false},
+		{"", 0, false},
+	}
+	for _, test := range tests {
+		delay, ok := parseRetryAfter(test.value, now)
+		if delay != test.delay || ok != test.ok {
+			t.Errorf("parseRetryAfter(%q) = %v, %v, want %v, %v", test.value, delay, ok, test.delay, test.ok)
+		}
+	}
+}
@@ -0,0 +1,205 @@
+package ghreport
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/dsciamma/graphql"
+)
+
+// maxSearchResults is the number of results the GitHub search returns at most
+const maxSearchResults = 1000
+
+// searchQuery searches the pull requests matching $query, one page at a time
+const searchQuery = `
+query ($query: String!, $size: Int!, $cursor: String, $date2: DateTime!) {
+  search(query: $query, type: ISSUE, first: $size, after: $cursor) {
+    nodes {
+      ... on PullRequest {
+        ...prFields
+        mergedAt
+        closedAt
+        state
+        isDraft
+        mergeable
+        mergeCommitOid: mergeCommit {
+          oid
+        }
+        timeline(since: $date2) {
+          totalCount
+        }
+        activity: timelineItems(since: $date2, itemTypes: [PULL_REQUEST_COMMIT, ISSUE_COMMENT, PULL_REQUEST_REVIEW]) {
+          totalCount
+        }
+        lastCommitAt: commits(last: 1) {
+          nodes {
+            commit {
+              committedDate
+            }
+          }
+        }
+        ...recentCommentsFields
+        searchRepository: repository {
+          name
+          pushedAt
+          visibility
+        }
+      }
+    }
+    pageInfo {
+      hasNextPage
+      endCursor
+    }
+    issueCount
+  }
+  rateLimit {
+    limit
+    cost
+    remaining
+    resetAt
+  }
+}
+` + prFieldsFragment + recentCommentsFragment
+
+// searchNodeStruct defines the structure sent by GitHub GraphQL API for a pull request found by a search
+type searchNodeStruct struct {
+	PRStruct
+	SearchRepository RepositoryStruct `json:"searchRepository"`
+}
+
+type searchResponseStruct struct {
+	Search struct {
+		Nodes      []searchNodeStruct
+		PageInfo   PageInfoStruct
+		IssueCount int
+	}
+	RateLimit RateLimitStruct
+}
+
+// searchPullRequests returns every pull request of organization matching the search qualifiers,
+// in the repositories passing the repository filters of the report
+func (gr *ActivityReport) searchPullRequests(
+	ctx context.Context,
+	client *graphql.Client,
+	organization string,
+	qualifiers string,
+	since time.Time) ([]PRStruct, error) {
+
+	pullrequests := []PRStruct{}
+	cursor := ""
+	for {
+		respData, err := gr.searchPage(ctx, client, organization, qualifiers, since, cursor)
+		if err != nil {
+			return nil, err
+		}
+		for _, node := range respData.Search.Nodes {
+			pullrequest := node.PRStruct
+			repository := node.SearchRepository.Name
+			if repository == "" || !gr.keepRepository(node.SearchRepository, since) {
+				continue
+			}
+			pullrequest.Org = organization
+			pullrequest.Repository = repository
+			if err := gr.completeParticipants(ctx, client, organization, repository, &pullrequest); err != nil {
+				return nil, err
+			}
+			pullrequests = append(pullrequests, pullrequest)
+		}
+		if !respData.Search.PageInfo.HasNextPage {
+			return pullrequests, nil
+		}
+		cursor = respData.Search.PageInfo.EndCursor
+	}
+}
+
+// searchPage returns the page of the pull requests of organization matching the search qualifiers
+// starting at cursor, the first one when cursor is empty
+func (gr *ActivityReport) searchPage(
+	ctx context.Context,
+	client *graphql.Client,
+	organization string,
+	qualifiers string,
+	since time.Time,
+	cursor string) (searchResponseStruct, error) {
+
+	owner := "org"
+	if gr.AccountType == AccountUser {
+		owner = "user"
+	}
+	query := fmt.Sprintf("%s:%s is:pr %s", owner, organization, qualifiers)
+	if gr.BaseBranch != "" {
+		query += fmt.Sprintf(" base:%q", gr.BaseBranch)
+	}
+	gr.logf("Searching %s\n", query)
+
+	var respData searchResponseStruct
+	if err := gr.checkRateLimit(ctx); err != nil {
+		return respData, err
+	}
+	req := graphql.NewRequest(searchQuery)
+	if cursor != "" {
+		req.Var("cursor", cursor)
+	}
+	req.Var("query", query)
+	req.Var("size", gr.PageSize)
+	req.Var("date2", since.UTC().Format(ISO_FORM))
+
+	if err := gr.runQuery(ctx, client, req, &respData); err != nil {
+		return respData, err
+	}
+	gr.recordRateLimit(respData.RateLimit)
+	return respData, nil
+}
+
+// searchSpec describes the search of the pull requests in a state
+type searchSpec struct {
+	state      string
+	name       string
+	qualifiers string
+}
+
+// searches returns the searches of the pull requests of the window from since to until.
+// States mapped to BucketIgnore are not searched at all.
+func (gr *ActivityReport) searches(since time.Time, until time.Time) []searchSpec {
+	window := since.UTC().Format(ISO_FORM) + ".." + until.UTC().Format(ISO_FORM)
+	searches := []searchSpec{}
+	for _, search := range []searchSpec{
+		{StateMerged, "merged", "is:merged merged:" + window},
+		{StateOpen, "open", "is:open"},
+		{StateClosed, "closed", "is:closed is:unmerged closed:" + window},
+	} {
+		if gr.bucketOf(search.state) != BucketIgnore {
+			searches = append(searches, search)
+		}
+	}
+	return searches
+}
+
+// generateFromSearch fills result with the pull requests of every organization of the report
+// found with the GitHub search, which bounds the merged and closed pull requests to the window
+// across all repositories instead of fetching the last ones of each repository.
+// Issues and commits are not collected.
+//
+// When a search fails, result still holds the pull requests of the searches already done.
+func (gr *ActivityReport) generateFromSearch(
+	ctx context.Context,
+	client *graphql.Client,
+	result *Result,
+	since time.Time,
+	until time.Time) error {
+
+	for _, organization := range gr.organizations() {
+		found := map[string][]PRStruct{}
+		for _, search := range gr.searches(since, until) {
+			pullrequests, err := gr.searchPullRequests(ctx, client, organization, search.qualifiers, since)
+			if err != nil {
+				gr.classifyPullRequests(result, found[StateMerged], found[StateOpen], found[StateClosed], since, until)
+				return fmt.Errorf("An error occured during search of %s pull requests of %s: %w", search.name, organization, err)
+			}
+			found[search.state] = pullrequests
+		}
+		gr.classifyPullRequests(result, found[StateMerged], found[StateOpen], found[StateClosed], since, until)
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package ghreport
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestRunWithSearchClassifiesResults(t *testing.T) {
+	var mu sync.Mutex
+	queries := []string{}
+	server := newGraphQLServer(t, func(req graphQLRequest, w http.ResponseWriter) {
+		query, _ := req.Variables["query"].(string)
+		mu.Lock()
+		queries = append(queries, query)
+		mu.Unlock()
+		var nodes string
+		switch {
+		case strings.Contains(query, "is:merged"):
+			nodes = fmt.Sprintf(`{"number":1,"state":"MERGED","mergedAt":%q,"searchRepository":{"name":"api"}}`, daysAgo(1))
+		case strings.Contains(query, "is:open"):
+			nodes = fmt.Sprintf(`{"number":2,"state":"OPEN","createdAt":%q,"activity":{"totalCount":2},"searchRepository":{"name":"web"}},
+				{"number":3,"state":"OPEN","createdAt":%q,"activity":{"totalCount":0},"searchRepository":{"name":"api"}}`,
+				daysAgo(20), daysAgo(20))
+		}
+		fmt.Fprintf(w, `{"data":{"search":{"nodes":[%s],"pageInfo":{"hasNextPage":false}}}}`, nodes)
+	})
+	report := newTestReport(server.URL)
+	report.UseSearch = true
+	if err := report.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	merged := report.Result.MergedPRs
+	if len(merged) != 1 || merged[0].Number != 1 || merged[0].Repository != "api" || merged[0].Org != "acme" {
+		t.Errorf("MergedPRs = %+v, want #1 of acme/api", merged)
+	}
+	if got := numbers(report.Result.OpenPRsWithActivity); got != "[2]" {
+		t.Errorf("OpenPRsWithActivity = %s, want [2]", got)
+	}
+	if got := numbers(report.Result.OpenPRsWithoutActivity); got != "[3]" {
+		t.Errorf("OpenPRsWithoutActivity = %s, want [3]", got)
+	}
+	for _, query := range queries {
+		if !strings.HasPrefix(query, "org:acme is:pr ") {
+			t.Errorf("search %q is not bounded to the pull requests of acme", query)
+		}
+	}
+	if len(queries) == 0 || !strings.Contains(queries[0], "merged:") {
+		t.Errorf("expected the merged search to be bounded to the window, got %q", queries)
+	}
+}
+
+func TestRunWithSearchFiltersRepositories(t *testing.T) {
+	server := newGraphQLServer(t, func(req graphQLRequest, w http.ResponseWriter) {
+		query, _ := req.Variables["query"].(string)
+		if !strings.Contains(query, "is:merged") {
+			fmt.Fprint(w, `{"data":{"search":{"nodes":[],"pageInfo":{"hasNextPage":false}}}}`)
+			return
+		}
+		fmt.Fprintf(w, `{"data":{"search":{"nodes":[
+			{"number":1,"mergedAt":%q,"searchRepository":{"name":"api","visibility":"PUBLIC","pushedAt":%q}},
+			{"number":2,"mergedAt":%q,"searchRepository":{"name":"web","visibility":"PRIVATE","pushedAt":%q}},
+			{"number":3,"mergedAt":%q,"searchRepository":{"name":"docs","visibility":"PUBLIC","pushedAt":%q}}],
+			"pageInfo":{"hasNextPage":false}}}}`,
+			daysAgo(1), daysAgo(1), daysAgo(1), daysAgo(1), daysAgo(1), daysAgo(30))
+	})
+	report := newTestReport(server.URL)
+	report.UseSearch = true
+	report.Visibility = VisibilityPublic
+	report.SkipInactiveRepos = true
+	if err := report.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if got := numbers(report.Result.MergedPRs); got != "[1]" {
+		t.Errorf("MergedPRs = %s, want only #1 of the public active repository api", got)
+	}
+}
+
+func TestRunWithSearchReturnsPartialResult(t *testing.T) {
+	server := newGraphQLServer(t, func(req graphQLRequest, w http.ResponseWriter) {
+		query, _ := req.Variables["query"].(string)
+		if !strings.Contains(query, "is:merged") {
+			http.Error(w, "boom", http.StatusBadRequest)
+			return
+		}
+		fmt.Fprintf(w, `{"data":{"search":{"nodes":[{"number":1,"mergedAt":%q,"searchRepository":{"name":"api"}}],
+			"pageInfo":{"hasNextPage":false}}}}`, daysAgo(1))
+	})
+	report := newTestReport(server.URL)
+	report.UseSearch = true
+	err := report.Run()
+	if !errors.Is(err, ErrIncomplete) {
+		t.Fatalf("expected an error wrapping ErrIncomplete, got %v", err)
+	}
+	if got := numbers(report.Result.MergedPRs); got != "[1]" {
+		t.Errorf("MergedPRs = %s, want the merged pull requests found before the failure", got)
+	}
+}
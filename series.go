@@ -0,0 +1,68 @@
+package ghreport
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// PeriodResult holds the counts of the report over one period of a series
+type PeriodResult struct {
+	Since                  time.Time `json:"since"`
+	Until                  time.Time `json:"until"`
+	MergedPRs              int       `json:"mergedPRs"`
+	OpenPRsWithActivity    int       `json:"openPRsWithActivity"`
+	OpenPRsWithoutActivity int       `json:"openPRsWithoutActivity"`
+	DraftPRs               int       `json:"draftPRs"`
+	ClosedPRs              int       `json:"closedPRs"`
+	Issues                 int       `json:"issues"`
+}
+
+// newPeriodResult counts the pull requests and issues of result over the period from since to until
+func newPeriodResult(result *Result, since time.Time, until time.Time) PeriodResult {
+	return PeriodResult{
+		Since:                  since,
+		Until:                  until,
+		MergedPRs:              len(result.MergedPRs),
+		OpenPRsWithActivity:    len(result.OpenPRsWithActivity),
+		OpenPRsWithoutActivity: len(result.OpenPRsWithoutActivity),
+		DraftPRs:               len(result.DraftPRs),
+		ClosedPRs:              len(result.ClosedPRs),
+		Issues:                 len(result.Issues),
+	}
+}
+
+// RunSeries generates the report over the configured window and the periods-1 windows of the same
+// length preceding it, e.g. the last 4 weeks with a Duration of 7 and periods of 4.
+// It returns the counts of each period, oldest first, and leaves the ActivityReport untouched.
+//
+// Pull requests are classified by their current state: open pull requests are those still open
+// now, and their activity is counted from the beginning of each period up to now.
+func (gr *ActivityReport) RunSeries(ctx context.Context, periods int) ([]PeriodResult, error) {
+	if periods < 1 {
+		return nil, fmt.Errorf("periods must be positive, got %d", periods)
+	}
+	if err := gr.Validate(); err != nil {
+		return nil, err
+	}
+
+	client := gr.newClient(ctx)
+
+	now := time.Now().In(gr.location())
+	since, until := gr.window(now)
+	length := until.Sub(since)
+
+	series := make([]PeriodResult, periods)
+	for i := 0; i < periods; i++ {
+		shift := time.Duration(i) * length
+		periodSince, periodUntil := since.Add(-shift), until.Add(-shift)
+		gr.logf("Reporting period from %s to %s\n", periodSince.Format(ISO_FORM), periodUntil.Format(ISO_FORM))
+		result, err := gr.generateWindow(ctx, client, now, periodSince, periodUntil)
+		if err != nil {
+			return nil, fmt.Errorf("An error occured during report of period from %s to %s: %w",
+				periodSince.Format(ISO_FORM), periodUntil.Format(ISO_FORM), err)
+		}
+		series[periods-1-i] = newPeriodResult(result, periodSince, periodUntil)
+	}
+	return series, nil
+}
@@ -0,0 +1,63 @@
+package ghreport
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestRunSeriesCountsEachPeriod(t *testing.T) {
+	server := newRepositoryServer(t, func(repo string) string {
+		return `"mergedPR":{"nodes":[
+			{"number":1,"mergedAt":"2020-01-06T10:00:00Z"},
+			{"number":2,"mergedAt":"2020-01-05T10:00:00Z"},
+			{"number":3,"mergedAt":"2019-12-28T10:00:00Z"},
+			{"number":4,"mergedAt":"2019-12-20T10:00:00Z"}]}`
+	}, "api")
+	report := newTestReport(server.URL)
+	report.StartDate = time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	report.EndDate = time.Date(2020, 1, 8, 0, 0, 0, 0, time.UTC)
+
+	series, err := report.RunSeries(context.Background(), 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(series) != 3 {
+		t.Fatalf("got %d periods, want 3", len(series))
+	}
+	expected := []struct {
+		since  string
+		merged int
+	}{{"2019-12-18", 1}, {"2019-12-25", 1}, {"2020-01-01", 2}}
+	for i, period := range series {
+		if got := period.Since.Format("2006-01-02"); got != expected[i].since || period.MergedPRs != expected[i].merged {
+			t.Errorf("period %d starts %s with %d merged, want %s with %d", i, got, period.MergedPRs, expected[i].since, expected[i].merged)
+		}
+		if period.Until.Sub(period.Since) != 7*24*time.Hour {
+			t.Errorf("period %d lasts %v, want a week", i, period.Until.Sub(period.Since))
+		}
+	}
+	if len(report.Result.MergedPRs) != 0 {
+		t.Error("RunSeries modified the result of the report")
+	}
+}
+
+func TestRunSeriesRejectsInvalidPeriods(t *testing.T) {
+	report := NewActivityReport("acme", "token", 7)
+	if _, err := report.RunSeries(context.Background(), 0); err == nil {
+		t.Error("expected an error for 0 periods")
+	}
+}
+
+func TestRunSeriesFailsWithPeriod(t *testing.T) {
+	server := newGraphQLServer(t, func(req graphQLRequest, w http.ResponseWriter) {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, "Not found")
+	})
+	report := newTestReport(server.URL)
+	if _, err := report.RunSeries(context.Background(), 2); err == nil {
+		t.Error("expected RunSeries to fail with the server")
+	}
+}
@@ -0,0 +1,92 @@
+package ghreport
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// DefaultSlackMaxPRs is the default number of pull requests listed per Slack section
+const DefaultSlackMaxPRs = 10
+
+// SlackText defines a Slack Block Kit text object
+type SlackText struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+// SlackBlock defines a Slack Block Kit section block
+type SlackBlock struct {
+	Type string    `json:"type"`
+	Text SlackText `json:"text"`
+}
+
+// SlackMessage defines a Slack message made of blocks
+type SlackMessage struct {
+	Blocks []SlackBlock `json:"blocks"`
+}
+
+// slackEscaper escapes the control characters of Slack mrkdwn
+var slackEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+func slackSection(text string) SlackBlock {
+	return SlackBlock{Type: "section", Text: SlackText{Type: "mrkdwn", Text: text}}
+}
+
+// SlackBlocks builds a Slack message summarizing the report: one block with the counts,
+// then one block per pull request for the most active open PRs and the merged PRs.
+// At most maxPRs pull requests are listed per section (DefaultSlackMaxPRs when maxPRs <= 0),
+// which keeps the message within Slack's block limits.
+func (gr *ActivityReport) SlackBlocks(maxPRs int) SlackMessage {
+	result := gr.exportResult()
+	if maxPRs <= 0 {
+		maxPRs = DefaultSlackMaxPRs
+	}
+
+	message := SlackMessage{Blocks: []SlackBlock{slackSection(fmt.Sprintf(
+		"*Activity report for %s*\nMerged: %d | Open with activity: %d | Open without activity: %d",
+		slackEscaper.Replace(strings.Join(gr.organizations(), ", ")),
+		len(result.MergedPRs),
+		len(result.OpenPRsWithActivity),
+		len(result.OpenPRsWithoutActivity)))}}
+
+	active := result.SortOpenByActivity()
+
+	sections := []struct {
+		title        string
+		pullrequests []PRStruct
+		showEvents   bool
+	}{
+		{"Most active open PRs", active, true},
+		{"Merged PRs", result.MergedPRs, false},
+	}
+	for _, section := range sections {
+		if len(section.pullrequests) == 0 {
+			continue
+		}
+		message.Blocks = append(message.Blocks, slackSection("*"+section.title+"*"))
+		for i, pr := range section.pullrequests {
+			if i == maxPRs {
+				message.Blocks = append(message.Blocks, slackSection(
+					fmt.Sprintf("_and %d more_", len(section.pullrequests)-maxPRs)))
+				break
+			}
+			text := fmt.Sprintf("<%s|%s#%d> %s",
+				gr.PullRequestURL(pr),
+				slackEscaper.Replace(pr.Repository),
+				pr.Number,
+				slackEscaper.Replace(pr.Title))
+			if section.showEvents {
+				text += fmt.Sprintf(" (%d events)", pr.Activity.TotalCount)
+			}
+			message.Blocks = append(message.Blocks, slackSection(text))
+		}
+	}
+	return message
+}
+
+// SlackJSON returns the Slack message built by SlackBlocks encoded as JSON,
+// ready to be posted to a Slack incoming webhook
+func (gr *ActivityReport) SlackJSON(maxPRs int) ([]byte, error) {
+	return json.Marshal(gr.SlackBlocks(maxPRs))
+}
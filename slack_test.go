@@ -0,0 +1,53 @@
+package ghreport
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestSlackJSON(t *testing.T) {
+	report := NewActivityReport("acme", "token", 7)
+	for i := 1; i <= 3; i++ {
+		pr := PRStruct{Repository: "api", Number: i, Title: "Change <b>"}
+		pr.Activity.TotalCount = i
+		report.Result.OpenPRsWithActivity = append(report.Result.OpenPRsWithActivity, pr)
+	}
+	report.Result.MergedPRs = []PRStruct{{Repository: "web", Number: 9, Title: "Fix"}}
+
+	data, err := report.SlackJSON(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var message struct {
+		Blocks []struct {
+			Type string
+			Text struct {
+				Type string
+				Text string
+			}
+		}
+	}
+	if err := json.Unmarshal(data, &message); err != nil {
+		t.Fatalf("invalid JSON %s: %v", data, err)
+	}
+	texts := []string{}
+	for _, block := range message.Blocks {
+		if block.Type != "section" || block.Text.Type != "mrkdwn" {
+			t.Errorf("unexpected block %+v", block)
+		}
+		texts = append(texts, block.Text.Text)
+	}
+	want := []string{
+		"*Activity report for acme*\nMerged: 1 | Open with activity: 3 | Open without activity: 0",
+		"*Most active open PRs*",
+		"<https://github.com/acme/api/pull/3|api#3> Change &lt;b&gt; (3 events)",
+		"<https://github.com/acme/api/pull/2|api#2> Change &lt;b&gt; (2 events)",
+		"_and 1 more_",
+		"*Merged PRs*",
+		"<https://github.com/acme/web/pull/9|web#9> Fix",
+	}
+	if strings.Join(texts, "\n---\n") != strings.Join(want, "\n---\n") {
+		t.Fatalf("blocks:\n%s\nwant:\n%s", strings.Join(texts, "\n---\n"), strings.Join(want, "\n---\n"))
+	}
+}
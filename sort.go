@@ -0,0 +1,84 @@
+package ghreport
+
+import (
+	"sort"
+	"time"
+)
+
+// sortedCopy returns a sorted copy of pullrequests, leaving the original slice untouched
+func sortedCopy(pullrequests []PRStruct, by func([]PRStruct) sort.Interface) []PRStruct {
+	sorted := make([]PRStruct, len(pullrequests))
+	copy(sorted, pullrequests)
+	sort.Stable(by(sorted))
+	return sorted
+}
+
+// sortedByTime returns a copy of pullrequests sorted chronologically on the timestamp returned by key.
+// Timestamps are parsed once; unparseable ones sort last.
+func sortedByTime(pullrequests []PRStruct, key func(PRStruct) string) []PRStruct {
+	type entry struct {
+		pr    PRStruct
+		t     time.Time
+		valid bool
+	}
+	entries := make([]entry, len(pullrequests))
+	for i, pr := range pullrequests {
+		t, err := time.Parse(ISO_FORM, key(pr))
+		entries[i] = entry{pr, t, err == nil}
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return lessTime(entries[i].t, entries[i].valid, entries[j].t, entries[j].valid)
+	})
+	sorted := make([]PRStruct, len(entries))
+	for i, e := range entries {
+		sorted[i] = e.pr
+	}
+	return sorted
+}
+
+func createdAt(pr PRStruct) string { return pr.CreatedAt }
+func mergedAt(pr PRStruct) string  { return pr.MergedAt }
+
+// SortOpenByActivity returns a copy of OpenPRsWithActivity sorted by decreasing number of events
+func (r *Result) SortOpenByActivity() []PRStruct {
+	return sortedCopy(r.OpenPRsWithActivity, func(prs []PRStruct) sort.Interface { return ByActivity(prs) })
+}
+
+// SortOpenByAge returns a copy of OpenPRsWithoutActivity sorted by creation date, oldest first
+func (r *Result) SortOpenByAge() []PRStruct {
+	return sortedByTime(r.OpenPRsWithoutActivity, createdAt)
+}
+
+// SortMergedByAge returns a copy of MergedPRs sorted by creation date, oldest first
+func (r *Result) SortMergedByAge() []PRStruct {
+	return sortedByTime(r.MergedPRs, createdAt)
+}
+
+// SortMergedByMerge returns a copy of MergedPRs sorted by merge date, oldest first
+func (r *Result) SortMergedByMerge() []PRStruct {
+	return sortedByTime(r.MergedPRs, mergedAt)
+}
+
+// topByActivity returns the n pull requests with the most events, most active first
+func topByActivity(pullrequests []PRStruct, n int) []PRStruct {
+	sorted := sortedCopy(pullrequests, func(prs []PRStruct) sort.Interface { return ByActivity(prs) })
+	if n < 0 {
+		n = 0
+	}
+	if n < len(sorted) {
+		sorted = sorted[:n]
+	}
+	return sorted
+}
+
+// TopActivePRs returns the n open pull requests with activity having the most commits,
+// comments and reviews in the window, most active first. Fewer are returned when there are not n of them.
+func (r *Result) TopActivePRs(n int) []PRStruct {
+	return topByActivity(r.OpenPRsWithActivity, n)
+}
+
+// TopActivePRsWithMerged is like TopActivePRs but also considers the merged pull requests
+func (r *Result) TopActivePRsWithMerged(n int) []PRStruct {
+	pullrequests := append(append([]PRStruct{}, r.OpenPRsWithActivity...), r.MergedPRs...)
+	return topByActivity(pullrequests, n)
+}
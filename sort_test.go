@@ -0,0 +1,96 @@
+package ghreport
+
+import (
+	"fmt"
+	"sort"
+	"testing"
+)
+
+// numbers returns the numbers of pullrequests
+func numbers(pullrequests []PRStruct) string {
+	n := []int{}
+	for _, pr := range pullrequests {
+		n = append(n, pr.Number)
+	}
+	return fmt.Sprint(n)
+}
+
+func TestSortHelpersReturnCopies(t *testing.T) {
+	r := &Result{
+		MergedPRs: []PRStruct{
+			{Number: 1, CreatedAt: "2020-01-03T00:00:00Z", MergedAt: "2020-01-04T00:00:00Z"},
+			{Number: 2, CreatedAt: "2020-01-01T00:00:00Z", MergedAt: "2020-01-05T00:00:00Z"},
+			{Number: 3, CreatedAt: "2020-01-02T00:00:00Z", MergedAt: "2020-01-03T00:00:00Z"},
+		},
+		OpenPRsWithActivity: []PRStruct{{Number: 4}, {Number: 5}, {Number: 6}},
+	}
+	for i, count := range []int{1, 5, 3} {
+		r.OpenPRsWithActivity[i].Activity.TotalCount = count
+	}
+
+	if got := numbers(r.SortMergedByAge()); got != "[2 3 1]" {
+		t.Errorf("SortMergedByAge() = %s, want [2 3 1]", got)
+	}
+	if got := numbers(r.SortMergedByMerge()); got != "[3 1 2]" {
+		t.Errorf("SortMergedByMerge() = %s, want [3 1 2]", got)
+	}
+	if got := numbers(r.SortOpenByActivity()); got != "[5 6 4]" {
+		t.Errorf("SortOpenByActivity() = %s, want [5 6 4]", got)
+	}
+	if got := numbers(r.MergedPRs); got != "[1 2 3]" {
+		t.Errorf("MergedPRs reordered to %s", got)
+	}
+	if got := numbers(r.OpenPRsWithActivity); got != "[4 5 6]" {
+		t.Errorf("OpenPRsWithActivity reordered to %s", got)
+	}
+}
+
+func TestByAgeSortsUnparseableDatesLast(t *testing.T) {
+	pullrequests := []PRStruct{
+		{Number: 1, CreatedAt: "garbage"},
+		{Number: 2, CreatedAt: "2020-01-02T00:00:00Z"},
+		{Number: 3, CreatedAt: ""},
+		{Number: 4, CreatedAt: "2020-01-01T00:00:00Z"},
+		{Number: 5, CreatedAt: "2020-01-03"},
+	}
+	for i := 0; i < 3; i++ {
+		sorted := append([]PRStruct{}, pullrequests...)
+		sort.Stable(ByAge(sorted))
+		if got := numbers(sorted); got != "[4 2 1 3 5]" {
+			t.Fatalf("ByAge order = %s, want [4 2 1 3 5]", got)
+		}
+	}
+	r := &Result{OpenPRsWithoutActivity: pullrequests}
+	if got := numbers(r.SortOpenByAge()); got != "[4 2 1 3 5]" {
+		t.Fatalf("SortOpenByAge() = %s, want [4 2 1 3 5]", got)
+	}
+}
+
+func TestTopActivePRs(t *testing.T) {
+	r := &Result{
+		OpenPRsWithActivity: []PRStruct{{Number: 1}, {Number: 2}, {Number: 3}},
+		MergedPRs:           []PRStruct{{Number: 4}},
+	}
+	for i, count := range []int{2, 7, 4} {
+		r.OpenPRsWithActivity[i].Activity.TotalCount = count
+		// Other timeline events do not count
+		r.OpenPRsWithActivity[i].Timeline.TotalCount = 10 - count
+	}
+	r.MergedPRs[0].Activity.TotalCount = 5
+
+	if got := numbers(r.TopActivePRs(2)); got != "[2 3]" {
+		t.Errorf("TopActivePRs(2) = %s, want [2 3]", got)
+	}
+	if got := numbers(r.TopActivePRs(10)); got != "[2 3 1]" {
+		t.Errorf("TopActivePRs(10) = %s, want every open pull request with activity", got)
+	}
+	if got := numbers(r.TopActivePRs(-1)); got != "[]" {
+		t.Errorf("TopActivePRs(-1) = %s, want none", got)
+	}
+	if got := numbers(r.TopActivePRsWithMerged(3)); got != "[2 4 3]" {
+		t.Errorf("TopActivePRsWithMerged(3) = %s, want [2 4 3]", got)
+	}
+	if got := numbers(r.OpenPRsWithActivity); got != "[1 2 3]" {
+		t.Errorf("OpenPRsWithActivity reordered to %s", got)
+	}
+}
@@ -0,0 +1,55 @@
+package ghreport
+
+import (
+	"bufio"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// tsvEscaper replaces the characters that would break the columns of a TSV row
+var tsvEscaper = strings.NewReplacer("\t", " ", "\r\n", " ", "\r", " ", "\n", " ")
+
+// WriteTSV writes the pull requests of the report as tab-separated values, ready to be pasted
+// in a spreadsheet: a header row, then one row per pull request with its bucket (merged,
+// openWithActivity, openWithoutActivity, closed or draft). Tabs and line breaks in values are
+// replaced by spaces.
+func (gr *ActivityReport) WriteTSV(w io.Writer) error {
+	result := gr.exportResult()
+	writer := bufio.NewWriter(w)
+	writeRow := func(values ...string) {
+		for i, value := range values {
+			if i > 0 {
+				writer.WriteString("\t")
+			}
+			writer.WriteString(tsvEscaper.Replace(value))
+		}
+		writer.WriteString("\n")
+	}
+	writeRow("bucket", "repository", "number", "title", "author", "createdAt", "mergedAt", "closedAt", "participants")
+	for _, bucket := range []struct {
+		name         string
+		pullrequests []PRStruct
+	}{
+		{"merged", result.MergedPRs},
+		{"openWithActivity", result.OpenPRsWithActivity},
+		{"openWithoutActivity", result.OpenPRsWithoutActivity},
+		{"closed", result.ClosedPRs},
+		{"draft", result.DraftPRs},
+	} {
+		for _, pr := range bucket.pullrequests {
+			writeRow(
+				bucket.name,
+				pr.Repository,
+				strconv.Itoa(pr.Number),
+				pr.Title,
+				pr.Author.Login,
+				gr.humanDate(pr.CreatedAt),
+				gr.humanDate(pr.MergedAt),
+				gr.humanDate(pr.ClosedAt),
+				strconv.Itoa(pr.Participants.TotalCount),
+			)
+		}
+	}
+	return writer.Flush()
+}
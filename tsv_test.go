@@ -0,0 +1,27 @@
+package ghreport
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestWriteTSV(t *testing.T) {
+	report := NewActivityReport("acme", "token", 7)
+	merged := PRStruct{Repository: "api", Number: 12, Title: "Fix\ttabs and\nnewlines", CreatedAt: "2020-01-01T10:00:00Z", MergedAt: "2020-01-02T11:30:00Z"}
+	merged.Author.Login = "alice"
+	merged.Participants.TotalCount = 2
+	report.Result.MergedPRs = []PRStruct{merged}
+	report.Result.OpenPRsWithoutActivity = []PRStruct{{Repository: "web", Number: 3, Title: "Redesign", CreatedAt: "2019-12-01T08:00:00Z"}}
+
+	var buf bytes.Buffer
+	if err := report.WriteTSV(&buf); err != nil {
+		t.Fatal(err)
+	}
+	checkGolden(t, "testdata/report.tsv", buf.Bytes())
+	for i, line := range strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n") {
+		if columns := strings.Count(line, "\t") + 1; columns != 9 {
+			t.Errorf("line %d has %d columns, want 9: %q", i, columns, line)
+		}
+	}
+}
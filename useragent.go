@@ -0,0 +1,32 @@
+package ghreport
+
+import (
+	"net/http"
+)
+
+// Version is the version of the library, sent in DefaultUserAgent
+const Version = "0.1.0"
+
+// DefaultUserAgent is the User-Agent header sent to GitHub by default
+const DefaultUserAgent = "ghreport/" + Version
+
+// userAgentTransport sets the User-Agent header of every request to the UserAgent of the report
+type userAgentTransport struct {
+	base   http.RoundTripper
+	report *ActivityReport
+}
+
+func (t *userAgentTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// A RoundTripper must not modify the request it is given
+	req = req.Clone(req.Context())
+	req.Header.Set("User-Agent", t.report.userAgent())
+	return t.base.RoundTrip(req)
+}
+
+// userAgent returns UserAgent, or DefaultUserAgent when it is not set
+func (gr *ActivityReport) userAgent() string {
+	if gr.UserAgent == "" {
+		return DefaultUserAgent
+	}
+	return gr.UserAgent
+}
@@ -0,0 +1,36 @@
+package ghreport
+
+import (
+	"testing"
+)
+
+func TestRunSendsDefaultUserAgent(t *testing.T) {
+	server, userAgents := newHeaderServer(t, "User-Agent")
+	report := newTestReport(server.URL)
+	if err := report.Run(); err != nil {
+		t.Fatal(err)
+	}
+	for _, userAgent := range userAgents() {
+		if userAgent != DefaultUserAgent {
+			t.Errorf("User-Agent = %q, want %q", userAgent, DefaultUserAgent)
+		}
+	}
+}
+
+func TestRunSendsUserAgent(t *testing.T) {
+	server, userAgents := newHeaderServer(t, "User-Agent")
+	report := newTestReport(server.URL)
+	report.UserAgent = "acme-dashboard/2.0"
+	if err := report.Run(); err != nil {
+		t.Fatal(err)
+	}
+	sent := userAgents()
+	if len(sent) == 0 {
+		t.Fatal("no request received")
+	}
+	for _, userAgent := range sent {
+		if userAgent != "acme-dashboard/2.0" {
+			t.Errorf("User-Agent = %q, want acme-dashboard/2.0", userAgent)
+		}
+	}
+}